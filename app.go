@@ -2,11 +2,17 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"runtime"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"AutoSoundWindows/audio"
+	"AutoSoundWindows/exposure"
 	"AutoSoundWindows/settings"
 
 	"github.com/energye/systray"
@@ -16,14 +22,87 @@ import (
 // App struct
 type App struct {
 	ctx             context.Context
-	audioManager    *audio.AudioManager
+	audioManager    audio.Backend
 	settingsManager *settings.SettingsManager
 	settings        *settings.Settings
 	stopNotifier    chan struct{}
 
+	// Защищает настройки устройств, которые меняет и горутина уведомлений,
+	// и сохранённый выбор устройств, который она читает
+	settingsMu sync.Mutex
+
+	// Фабрика аудио бэкенда; в тестах подменяется на audio.FakeBackend
+	newBackend func() (audio.Backend, error)
+
+	// Плавные переходы громкости; nil - громкость меняется сразу
+	ramper *audio.Ramper
+
+	// Последнее срабатывание потолка громкости
+	capMu     sync.Mutex
+	capStatus VolumeCapStatus
+
+	// Звуковая нагрузка на наушники; nil - история недоступна
+	exposure *exposure.Tracker
+	// Сведения об устройствах вывода для учёта нагрузки;
+	// используются только горутиной уведомлений
+	exposureDevices map[string]exposureDevice
+
+	// Счётчики внешних изменений громкости, которые AutoSound отменил
+	// фиксацией, диапазоном или потолком; собственные записи не учитываются
+	outputInterference atomic.Int64
+	inputInterference  atomic.Int64
+
 	// Временный выбор (до сохранения)
-	pendingOutputID string
-	pendingInputID  string
+	pendingOutputPriority []string
+	pendingInputPriority  []string
+	pendingOutputRoles    map[string]string
+	pendingInputRoles     map[string]string
+
+	// Устройства, выбранные из списков приоритетов при последней проверке;
+	// используются только горутиной уведомлений
+	activeOutputID string
+	activeInputID  string
+
+	// Борьба с другой программой за устройство по умолчанию
+	outputConflict flapGuard
+	inputConflict  flapGuard
+
+	// Приостановка восстановления до перезапуска; пауза со сроком
+	// хранится в настройках. Срок меняется только под pauseMu, но читается
+	// и пишется под settingsMu: его сохраняет saveSettings.
+	pauseMu            sync.Mutex
+	pausedUntilRestart bool
+
+	// Последнее разрешённое устройство роли multimedia по направлениям:
+	// к нему AutoSound возвращается, если его вытеснит запрещённое.
+	// Используется только горутиной уведомлений.
+	allowedDefaults map[audio.EDataFlow]string
+
+	// Режим следования: подключения устройств и переключения на них.
+	// Используются только горутиной уведомлений.
+	presence *audio.PresenceTracker
+	followed map[audio.EDataFlow]*followFlow
+
+	// Вопросы об устройствах, выбранных в обход AutoSound, по направлениям
+	learnMu        sync.Mutex
+	learnProposals map[audio.EDataFlow]*learnProposal
+	trayLearn      map[audio.EDataFlow]learnMenu
+
+	// Сигнал горутине уведомлений проверить всё сразу: началась или
+	// закончилась пауза, пришёл ответ на вопрос об устройстве
+	wakeNotifier chan struct{}
+
+	// Устройства по умолчанию при последней проверке: их смена означает,
+	// что нужно применить запомненную громкость нового устройства
+	defaultOutputID string
+	defaultInputID  string
+
+	// Строки состояния в подсказке трея по ключам; до запуска трея
+	// подсказка только запоминается
+	trayMu     sync.Mutex
+	trayStatus map[string]string
+	trayReady  bool
+	trayResume *systray.MenuItem
 }
 
 // AudioDevice для фронтенда
@@ -33,12 +112,37 @@ type AudioDeviceInfo struct {
 	IsDefault bool   `json:"isDefault"`
 	IsChosen  bool   `json:"isChosen"`
 	IsPending bool   `json:"isPending"`
+	State     string `json:"state"`    // active, disabled, notpresent, unplugged или missing
+	Priority  int    `json:"priority"` // место в списке до сохранения (1 - главное, 0 - нет в списке)
+
+	// Роли ("console", "multimedia", "communications"): системные,
+	// сохранённые и выбранные до сохранения
+	DefaultRoles []string `json:"defaultRoles"`
+	ChosenRoles  []string `json:"chosenRoles"`
+	PendingRoles []string `json:"pendingRoles"`
+	// Роли, для которых устройство запрещено списком запретов
+	BlockedRoles []string `json:"blockedRoles"`
+	// Не переходить на устройство при подключении в режиме follow
+	NoFollow bool `json:"noFollow"`
+
+	// Сведения из хранилища свойств
+	FormFactor    string `json:"formFactor"` // speakers, headphones, headset, hdmi...
+	Description   string `json:"description"`
+	InterfaceName string `json:"interfaceName"`
+	ContainerID   string `json:"containerId"`
+	Enumerator    string `json:"enumerator"`
+	JackSubType   string `json:"jackSubType"`
+	IconPath      string `json:"iconPath"`
+	// Подсказка для различения устройств с одинаковыми именами
+	Hint string `json:"hint"`
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	return &App{
 		stopNotifier: make(chan struct{}),
+		wakeNotifier: make(chan struct{}, 1),
+		newBackend:   audio.NewBackend,
 	}
 }
 
@@ -60,15 +164,26 @@ func (a *App) startup(ctx context.Context) {
 	}
 
 	// Инициализируем pending значения из сохранённых
-	a.pendingOutputID = a.settings.OutputDeviceID
-	a.pendingInputID = a.settings.InputDeviceID
+	a.ResetChanges()
 
 	// Инициализация аудио менеджера
-	a.audioManager, err = audio.NewAudioManager()
+	a.audioManager, err = a.newBackend()
 	if err != nil {
 		log.Printf("Failed to create audio manager: %v", err)
 	}
 
+	// История нагрузки хранится рядом с настройками
+	a.exposure = a.newExposureTracker()
+
+	// Переходы громкости идут в своей горутине
+	a.ramper, err = audio.NewRamper(a.newBackend)
+	if err != nil {
+		log.Printf("Failed to start volume ramper: %v", err)
+	}
+
+	// Пауза могла остаться с прошлого запуска
+	a.updatePauseStatus()
+
 	// Запускаем systray
 	go a.initSystray()
 
@@ -80,22 +195,77 @@ func (a *App) startup(ctx context.Context) {
 func (a *App) shutdown(ctx context.Context) {
 	close(a.stopNotifier)
 	systray.Quit()
+	if a.ramper != nil {
+		a.ramper.Close()
+	}
 	if a.audioManager != nil {
 		a.audioManager.Close()
 	}
 }
 
+// trayTooltip - подсказка иконки в трее
+const trayTooltip = "AutoSound - Управление аудиоустройствами"
+
+// Ключи строк состояния в подсказке трея; строки идут в порядке ключей
+const (
+	trayConflictInput  = "conflict-input"
+	trayConflictOutput = "conflict-output"
+	trayExposure       = "exposure"
+	trayPause          = "pause"
+	trayLearnOutput    = "learn-output"
+	trayLearnInput     = "learn-input"
+)
+
+// setTrayStatus добавляет строку состояния к подсказке трея;
+// пустой text убирает строку
+func (a *App) setTrayStatus(key, text string) {
+	a.trayMu.Lock()
+	defer a.trayMu.Unlock()
+
+	if a.trayStatus[key] == text {
+		return
+	}
+	if text == "" {
+		delete(a.trayStatus, key)
+	} else {
+		if a.trayStatus == nil {
+			a.trayStatus = make(map[string]string)
+		}
+		a.trayStatus[key] = text
+	}
+	if a.trayReady {
+		systray.SetTooltip(a.trayTooltip())
+	}
+}
+
+// trayTooltip собирает подсказку трея; вызывается под a.trayMu
+func (a *App) trayTooltip() string {
+	tooltip := trayTooltip
+	for _, k := range slices.Sorted(maps.Keys(a.trayStatus)) {
+		tooltip += "\n" + a.trayStatus[k]
+	}
+	return tooltip
+}
+
 func (a *App) initSystray() {
 	systray.Run(func() {
 		// Используем встроенную иконку Windows (динамик)
 		systray.SetIcon(icon)
 		systray.SetTitle("AutoSound")
-		systray.SetTooltip("AutoSound - Управление аудиоустройствами")
 
 		mShow := systray.AddMenuItem("Открыть", "Открыть окно")
+		a.addPauseMenu()
+		a.addLearnMenu()
 		systray.AddSeparator()
 		mQuit := systray.AddMenuItem("Выход", "Закрыть программу")
 
+		// Строки состояния, появившиеся до запуска трея
+		a.trayMu.Lock()
+		a.trayReady = true
+		systray.SetTooltip(a.trayTooltip())
+		a.trayMu.Unlock()
+		a.updatePauseStatus()
+
 		// Клик по иконке
 		systray.SetOnClick(func(menu systray.IMenu) {
 			wailsRuntime.WindowShow(a.ctx)
@@ -132,62 +302,216 @@ func (a *App) HideWindow() {
 
 // GetOutputDevices возвращает устройства вывода
 func (a *App) GetOutputDevices() []AudioDeviceInfo {
+	saved, pending := a.selections(audio.ERender)
+	return a.listDevices(audio.ERender, saved, pending, a.blockedDevices(audio.ERender))
+}
+
+// GetInputDevices возвращает устройства ввода
+func (a *App) GetInputDevices() []AudioDeviceInfo {
+	saved, pending := a.selections(audio.ECapture)
+	return a.listDevices(audio.ECapture, saved, pending, a.blockedDevices(audio.ECapture))
+}
+
+// deviceSelection - выбор устройств одного направления
+type deviceSelection struct {
+	priority []string
+	roles    map[string]string
+}
+
+// ids возвращает все устройства, упомянутые в выборе
+func (sel deviceSelection) ids() []string {
+	ids := slices.Clone(sel.priority)
+	for _, role := range audio.Roles {
+		if id := sel.roles[role.String()]; id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// listDevices собирает список для фронтенда. Неактивные устройства
+// показываются, если включено ShowInactiveDevices или если они выбраны;
+// выбранные устройства, которых уже нет в системе, получают состояние "missing".
+func (a *App) listDevices(dataFlow audio.EDataFlow, saved, pending deviceSelection, blocked []settings.BlockRule) []AudioDeviceInfo {
 	if a.audioManager == nil {
 		return []AudioDeviceInfo{}
 	}
 
-	devices, err := a.audioManager.GetOutputDevices()
+	kind := "output"
+	if dataFlow == audio.ECapture {
+		kind = "input"
+	}
+
+	devices, err := a.audioManager.GetDevices(dataFlow, audio.DEVICE_STATEMASK_ALL)
 	if err != nil {
-		log.Printf("Failed to get output devices: %v", err)
+		log.Printf("Failed to get %s devices: %v", kind, err)
 		return []AudioDeviceInfo{}
 	}
 
-	result := make([]AudioDeviceInfo, len(devices))
-	for i, dev := range devices {
-		result[i] = AudioDeviceInfo{
-			ID:        dev.ID,
-			Name:      dev.Name,
-			IsDefault: dev.IsDefault,
-			IsChosen:  dev.ID == a.settings.OutputDeviceID,
-			IsPending: dev.ID == a.pendingOutputID,
+	stateMask := uint32(audio.DEVICE_STATE_ACTIVE)
+	if a.settings.ShowInactiveDevices {
+		stateMask = audio.DEVICE_STATEMASK_ALL
+	}
+	chosen := append(pending.ids(), saved.ids()...)
+
+	result := make([]AudioDeviceInfo, 0, len(devices))
+	listed := make(map[string]bool, len(devices))
+	for _, dev := range devices {
+		if dev.State&stateMask == 0 && !slices.Contains(chosen, dev.ID) {
+			continue
+		}
+		listed[dev.ID] = true
+		info := deviceInfo(dev.ID, dev.Name, audio.StateName(dev.State), saved, pending)
+		info.IsDefault = dev.IsDefault
+		info.DefaultRoles = roleNames(dev.DefaultRoles)
+		info.FormFactor = dev.FormFactor.String()
+		info.Description = dev.Description
+		info.InterfaceName = dev.InterfaceName
+		info.ContainerID = dev.ContainerID
+		info.Enumerator = dev.Enumerator
+		info.JackSubType = dev.JackSubType
+		info.IconPath = dev.IconPath
+		info.NoFollow = a.deviceSettings(dev.ID).NoFollow
+		for _, role := range audio.Roles {
+			if deviceBlocked(blocked, dev.ID, dev.Name, role) {
+				info.BlockedRoles = append(info.BlockedRoles, role.String())
+			}
+		}
+		result = append(result, info)
+	}
+	addDuplicateHints(result)
+
+	for _, id := range chosen {
+		if !listed[id] {
+			listed[id] = true
+			result = append(result, deviceInfo(id, "Устройство не найдено", "missing", saved, pending))
 		}
 	}
 	return result
 }
 
-// GetInputDevices возвращает устройства ввода
-func (a *App) GetInputDevices() []AudioDeviceInfo {
-	if a.audioManager == nil {
-		return []AudioDeviceInfo{}
+// addDuplicateHints подписывает устройства с одинаковыми именами шиной
+// и концом ContainerID, который у разных физических устройств различается
+func addDuplicateHints(devices []AudioDeviceInfo) {
+	counts := make(map[string]int, len(devices))
+	for _, dev := range devices {
+		counts[dev.Name]++
 	}
 
-	devices, err := a.audioManager.GetInputDevices()
-	if err != nil {
-		log.Printf("Failed to get input devices: %v", err)
-		return []AudioDeviceInfo{}
+	for i := range devices {
+		dev := &devices[i]
+		if counts[dev.Name] < 2 {
+			continue
+		}
+		hint := dev.Enumerator
+		if id := strings.Trim(dev.ContainerID, "{}"); len(id) >= 4 {
+			if hint != "" {
+				hint += " · "
+			}
+			hint += id[len(id)-4:]
+		}
+		dev.Hint = hint
 	}
+}
 
-	result := make([]AudioDeviceInfo, len(devices))
-	for i, dev := range devices {
-		result[i] = AudioDeviceInfo{
-			ID:        dev.ID,
-			Name:      dev.Name,
-			IsDefault: dev.IsDefault,
-			IsChosen:  dev.ID == a.settings.InputDeviceID,
-			IsPending: dev.ID == a.pendingInputID,
-		}
+// deviceInfo заполняет поля выбора AudioDeviceInfo
+func deviceInfo(id string, name string, state string, saved, pending deviceSelection) AudioDeviceInfo {
+	return AudioDeviceInfo{
+		ID:        id,
+		Name:      name,
+		State:     state,
+		IsChosen:  id == firstOf(saved.priority),
+		IsPending: id == firstOf(pending.priority),
+		Priority:  slices.Index(pending.priority, id) + 1,
+
+		DefaultRoles: []string{},
+		BlockedRoles: []string{},
+		ChosenRoles:  chosenRoles(id, firstOf(saved.priority), saved.roles),
+		PendingRoles: chosenRoles(id, firstOf(pending.priority), pending.roles),
+
+		FormFactor: audio.UnknownFormFactor.String(),
 	}
-	return result
 }
 
-// SelectOutputDevice выбирает устройство (временно, до сохранения)
+// GetShowInactiveDevices возвращает, показываются ли отключённые устройства
+func (a *App) GetShowInactiveDevices() bool {
+	return a.settings.ShowInactiveDevices
+}
+
+// SetShowInactiveDevices включает показ отключённых устройств
+func (a *App) SetShowInactiveDevices(enabled bool) {
+	a.settings.ShowInactiveDevices = enabled
+	a.saveSettings()
+}
+
+// SelectOutputDevice выбирает главное устройство (временно, до сохранения).
+// Прежний выбор остаётся в списке следующим, как запасной.
 func (a *App) SelectOutputDevice(deviceID string) {
-	a.pendingOutputID = deviceID
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.pendingOutputPriority = moveToFront(a.pendingOutputPriority, deviceID)
 }
 
-// SelectInputDevice выбирает устройство (временно, до сохранения)
+// SelectInputDevice выбирает главное устройство (временно, до сохранения).
+// Прежний выбор остаётся в списке следующим, как запасной.
 func (a *App) SelectInputDevice(deviceID string) {
-	a.pendingInputID = deviceID
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.pendingInputPriority = moveToFront(a.pendingInputPriority, deviceID)
+}
+
+// GetOutputPriority возвращает список приоритетов вывода (до сохранения)
+func (a *App) GetOutputPriority() []string {
+	_, pending := a.selections(audio.ERender)
+	return pending.priority
+}
+
+// GetInputPriority возвращает список приоритетов ввода (до сохранения)
+func (a *App) GetInputPriority() []string {
+	_, pending := a.selections(audio.ECapture)
+	return pending.priority
+}
+
+// SetOutputPriority задаёт порядок устройств вывода (временно, до сохранения)
+func (a *App) SetOutputPriority(deviceIDs []string) {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.pendingOutputPriority = normalizePriority(deviceIDs)
+}
+
+// SetInputPriority задаёт порядок устройств ввода (временно, до сохранения)
+func (a *App) SetInputPriority(deviceIDs []string) {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.pendingInputPriority = normalizePriority(deviceIDs)
+}
+
+// SelectOutputDeviceForRole выбирает устройство вывода для одной роли
+// (временно, до сохранения). Пустой deviceID возвращает роль к общему устройству.
+func (a *App) SelectOutputDeviceForRole(deviceID string, role string) error {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return selectRoleDevice(a.pendingOutputRoles, deviceID, role)
+}
+
+// SelectInputDeviceForRole выбирает устройство ввода для одной роли
+// (временно, до сохранения). Пустой deviceID возвращает роль к общему устройству.
+func (a *App) SelectInputDeviceForRole(deviceID string, role string) error {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return selectRoleDevice(a.pendingInputRoles, deviceID, role)
+}
+
+func selectRoleDevice(roles map[string]string, deviceID string, role string) error {
+	if _, ok := audio.ParseRole(role); !ok {
+		return fmt.Errorf("unknown role: %s", role)
+	}
+	if deviceID == "" {
+		delete(roles, role)
+	} else {
+		roles[role] = deviceID
+	}
+	return nil
 }
 
 // SaveSettings сохраняет выбранные устройства и применяет их
@@ -196,51 +520,191 @@ func (a *App) SaveSettings() error {
 		return nil
 	}
 
-	// Применяем устройство вывода
-	if a.pendingOutputID != "" && a.pendingOutputID != a.settings.OutputDeviceID {
-		if err := a.audioManager.SetDefaultDevice(a.pendingOutputID); err != nil {
-			log.Printf("Failed to set output device: %v", err)
-			return err
-		}
-		a.settings.OutputDeviceID = a.pendingOutputID
+	// Применяем устройства вывода. Если ни одно устройство списка
+	// не подключено, выбор всё равно сохраняется и применится при подключении.
+	if err := a.applySelection(audio.ERender); err != nil {
+		log.Printf("Failed to set output device: %v", err)
+		return err
 	}
 
-	// Применяем устройство ввода
-	if a.pendingInputID != "" && a.pendingInputID != a.settings.InputDeviceID {
-		if err := a.audioManager.SetDefaultDevice(a.pendingInputID); err != nil {
-			log.Printf("Failed to set input device: %v", err)
-			return err
-		}
-		a.settings.InputDeviceID = a.pendingInputID
+	// Применяем устройства ввода
+	if err := a.applySelection(audio.ECapture); err != nil {
+		log.Printf("Failed to set input device: %v", err)
+		return err
 	}
 
+	// Новый выбор - повод снова попробовать восстановление
+	a.outputConflict.reset()
+	a.inputConflict.reset()
+	a.setTrayStatus(trayConflictOutput, "")
+	a.setTrayStatus(trayConflictInput, "")
+	a.clearLearnProposals()
+
 	// Сохраняем настройки
-	a.settingsManager.Save(a.settings)
-	log.Printf("Settings saved: output=%s, input=%s", a.settings.OutputDeviceID, a.settings.InputDeviceID)
+	a.saveSettings()
+	log.Printf("Settings saved: output=%s, input=%s",
+		firstOf(a.savedSelection(audio.ERender).priority), firstOf(a.savedSelection(audio.ECapture).priority))
+	return nil
+}
+
+// applySelection применяет несохранённый выбор направления и делает его
+// сохранённым; выбор без изменений не трогается
+func (a *App) applySelection(dataFlow audio.EDataFlow) error {
+	saved, pending := a.selections(dataFlow)
+	if !selectionChanged(saved, pending) {
+		return nil
+	}
+	if _, _, err := applyRoleDevices(a.audioManager, dataFlow, pending.priority, pending.roles, a.blockedDevices(dataFlow), ""); err != nil {
+		return err
+	}
+
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	if dataFlow == audio.ECapture {
+		a.settings.InputPriority = pending.priority
+		a.settings.InputDeviceID = firstOf(pending.priority)
+		a.settings.InputRoleDevices = pending.roles
+	} else {
+		a.settings.OutputPriority = pending.priority
+		a.settings.OutputDeviceID = firstOf(pending.priority)
+		a.settings.OutputRoleDevices = pending.roles
+	}
 	return nil
 }
 
 // HasUnsavedChanges проверяет есть ли несохранённые изменения
 func (a *App) HasUnsavedChanges() bool {
-	return a.pendingOutputID != a.settings.OutputDeviceID ||
-		a.pendingInputID != a.settings.InputDeviceID
+	return a.outputSelectionChanged() || a.inputSelectionChanged()
+}
+
+func (a *App) outputSelectionChanged() bool {
+	return selectionChanged(a.selections(audio.ERender))
+}
+
+func (a *App) inputSelectionChanged() bool {
+	return selectionChanged(a.selections(audio.ECapture))
+}
+
+// selectionChanged сообщает, отличается ли несохранённый выбор от сохранённого
+func selectionChanged(saved, pending deviceSelection) bool {
+	return !slices.Equal(pending.priority, saved.priority) || !maps.Equal(pending.roles, saved.roles)
+}
+
+// selections возвращает сохранённый выбор направления и копию
+// несохранённого. Несохранённый выбор меняют привязки, а режим обучения
+// меняет оба, поэтому они читаются под settingsMu.
+func (a *App) selections(dataFlow audio.EDataFlow) (saved, pending deviceSelection) {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	if dataFlow == audio.ECapture {
+		return deviceSelection{a.settings.InputPriority, a.settings.InputRoleDevices},
+			deviceSelection{slices.Clone(a.pendingInputPriority), maps.Clone(a.pendingInputRoles)}
+	}
+	return deviceSelection{a.settings.OutputPriority, a.settings.OutputRoleDevices},
+		deviceSelection{slices.Clone(a.pendingOutputPriority), maps.Clone(a.pendingOutputRoles)}
 }
 
 // ResetChanges сбрасывает несохранённые изменения
 func (a *App) ResetChanges() {
-	a.pendingOutputID = a.settings.OutputDeviceID
-	a.pendingInputID = a.settings.InputDeviceID
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	a.pendingOutputPriority = slices.Clone(a.settings.OutputPriority)
+	a.pendingInputPriority = slices.Clone(a.settings.InputPriority)
+	a.pendingOutputRoles = cloneRoles(a.settings.OutputRoleDevices)
+	a.pendingInputRoles = cloneRoles(a.settings.InputRoleDevices)
+}
+
+// cloneRoles копирует назначения ролей; результат никогда не nil
+func cloneRoles(roles map[string]string) map[string]string {
+	result := make(map[string]string, len(roles))
+	maps.Copy(result, roles)
+	return result
+}
+
+// ptrTo возвращает указатель на копию значения для необязательных полей настроек
+func ptrTo[T any](v T) *T {
+	return &v
+}
+
+// valueOr возвращает значение необязательного поля или def, если оно не задано
+func valueOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// firstOf возвращает главное устройство списка приоритетов
+func firstOf(priority []string) string {
+	if len(priority) == 0 {
+		return ""
+	}
+	return priority[0]
+}
+
+// moveToFront ставит устройство первым, сохраняя порядок остальных
+func moveToFront(priority []string, deviceID string) []string {
+	if deviceID == "" {
+		return priority
+	}
+	result := []string{deviceID}
+	for _, id := range priority {
+		if id != deviceID {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// normalizePriority убирает пустые и повторяющиеся ID
+func normalizePriority(deviceIDs []string) []string {
+	result := make([]string, 0, len(deviceIDs))
+	for _, id := range deviceIDs {
+		if id != "" && !slices.Contains(result, id) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// effectiveDevice возвращает устройство роли: назначенное ей или общее
+func effectiveDevice(mainID string, roles map[string]string, role audio.ERole) string {
+	if deviceID := roles[role.String()]; deviceID != "" {
+		return deviceID
+	}
+	return mainID
+}
+
+// chosenRoles возвращает роли, для которых выбран deviceID
+func chosenRoles(deviceID string, mainID string, roles map[string]string) []string {
+	result := []string{}
+	for _, role := range audio.Roles {
+		if effectiveDevice(mainID, roles, role) == deviceID {
+			result = append(result, role.String())
+		}
+	}
+	return result
+}
+
+// roleNames переводит роли в имена для фронтенда
+func roleNames(roles []audio.ERole) []string {
+	result := make([]string, len(roles))
+	for i, role := range roles {
+		result[i] = role.String()
+	}
+	return result
 }
 
 // GetAutoSwitch возвращает состояние автопереключения
 func (a *App) GetAutoSwitch() bool {
-	return a.settings.AutoSwitch
+	return a.currentSettings().AutoSwitch
 }
 
 // SetAutoSwitch устанавливает автопереключение
 func (a *App) SetAutoSwitch(enabled bool) {
-	a.settings.AutoSwitch = enabled
-	a.settingsManager.Save(a.settings)
+	a.updateSettings(func(s *settings.Settings) {
+		s.AutoSwitch = enabled
+	})
 }
 
 // Quit закрывает приложение
@@ -253,78 +717,809 @@ type VolumeInfo struct {
 	OutputVolume float32 `json:"outputVolume"`
 	InputVolume  float32 `json:"inputVolume"`
 	LockVolume   bool    `json:"lockVolume"`
+	OutputMuted  bool    `json:"outputMuted"`
+	InputMuted   bool    `json:"inputMuted"`
+	LockMute     bool    `json:"lockMute"`
+
+	// Устройства по умолчанию и запомненная для них громкость (nil - не задана)
+	OutputDeviceID string   `json:"outputDeviceId"`
+	InputDeviceID  string   `json:"inputDeviceId"`
+	OutputSaved    *float32 `json:"outputSaved,omitempty"`
+	InputSaved     *float32 `json:"inputSaved,omitempty"`
+
+	// Громкость в децибелах (0, если устройство её не сообщает)
+	OutputDB float32 `json:"outputDb"`
+	InputDB  float32 `json:"inputDb"`
+
+	// Количество отменённых внешних изменений громкости с момента запуска
+	OutputInterference int64 `json:"outputInterference"`
+	InputInterference  int64 `json:"inputInterference"`
 }
 
-// GetVolumes возвращает текущие уровни громкости
+// GetVolumes возвращает текущие уровни громкости устройств по умолчанию
+// и запомненную для них громкость
 func (a *App) GetVolumes() VolumeInfo {
+	current := a.currentSettings()
+	info := VolumeInfo{
+		LockVolume:         current.LockVolume,
+		LockMute:           current.LockMute,
+		OutputInterference: a.outputInterference.Load(),
+		InputInterference:  a.inputInterference.Load(),
+	}
 	if a.audioManager == nil {
-		return VolumeInfo{
-			OutputVolume: a.settings.OutputVolume,
-			InputVolume:  a.settings.InputVolume,
-			LockVolume:   a.settings.LockVolume,
-		}
+		return info
 	}
 
-	outputVol, err := a.audioManager.GetDefaultOutputVolume()
+	info.OutputDeviceID = a.audioManager.GetCurrentDefaultOutputID()
+	info.OutputVolume, info.OutputMuted, info.OutputSaved = a.deviceVolumeState(info.OutputDeviceID)
+	info.OutputDB, _ = a.audioManager.GetDeviceVolumeDB(info.OutputDeviceID)
+
+	info.InputDeviceID = a.audioManager.GetCurrentDefaultInputID()
+	info.InputVolume, info.InputMuted, info.InputSaved = a.deviceVolumeState(info.InputDeviceID)
+	info.InputDB, _ = a.audioManager.GetDeviceVolumeDB(info.InputDeviceID)
+
+	return info
+}
+
+// deviceVolumeState читает громкость и звук устройства; если прочитать
+// не удалось, показывает запомненные значения. Громкость переводится
+// в положение слайдера по кривой устройства.
+func (a *App) deviceVolumeState(deviceID string) (float32, bool, *float32) {
+	dev := a.deviceSettings(deviceID)
+
+	level, err := a.audioManager.GetDeviceVolume(deviceID)
 	if err != nil {
-		outputVol = a.settings.OutputVolume
+		level = valueOr(dev.Volume, 0)
+	}
+	// Пока идёт переход, слайдер показывает его цель
+	if a.ramper != nil {
+		if target, ok := a.ramper.Target(deviceID); ok {
+			level = target
+		}
 	}
 
-	inputVol, err := a.audioManager.GetDefaultInputVolume()
+	muted, err := a.audioManager.GetDeviceMute(deviceID)
 	if err != nil {
-		inputVol = a.settings.InputVolume
+		muted = valueOr(dev.Muted, false)
 	}
 
-	return VolumeInfo{
-		OutputVolume: outputVol,
-		InputVolume:  inputVol,
-		LockVolume:   a.settings.LockVolume,
+	curve := a.volumeCurve(deviceID)
+	var saved *float32
+	if dev.Volume != nil {
+		saved = ptrTo(curve.FromDevice(*dev.Volume))
 	}
+	return curve.FromDevice(level), muted, saved
 }
 
-// SetOutputVolume устанавливает громкость вывода
+// SetOutputVolume устанавливает громкость вывода и запоминает её для устройства
 func (a *App) SetOutputVolume(level float32) error {
+	err := a.setDefaultVolume(audio.ERender, level)
+	if err != nil {
+		log.Printf("Failed to set output volume: %v", err)
+	}
+	return err
+}
+
+// SetInputVolume устанавливает громкость ввода (микрофон) и запоминает её для устройства
+func (a *App) SetInputVolume(level float32) error {
+	err := a.setDefaultVolume(audio.ECapture, level)
+	if err != nil {
+		log.Printf("Failed to set input volume: %v", err)
+	}
+	return err
+}
+
+// setDefaultVolume меняет громкость устройства по умолчанию;
+// position - положение слайдера, которое переводится кривой устройства
+func (a *App) setDefaultVolume(dataFlow audio.EDataFlow, position float32) error {
 	if a.audioManager == nil {
 		return nil
 	}
+	deviceID := a.audioManager.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+	if deviceID == "" {
+		return fmt.Errorf("no default device")
+	}
 
-	err := a.audioManager.SetDefaultOutputVolume(level)
-	if err != nil {
-		log.Printf("Failed to set output volume: %v", err)
+	// Слайдер не может вывести громкость за диапазон устройства
+	saved := a.deviceSettings(deviceID)
+	level := saved.CapVolume(saved.LimitVolume(a.volumeCurve(deviceID).ToDevice(position)))
+	if err := a.rampVolume(a.audioManager, deviceID, level); err != nil {
 		return err
 	}
 
-	a.settings.OutputVolume = level
-	a.settingsManager.Save(a.settings)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.Volume = ptrTo(level)
+		dev.VolumeDB = nil
+	})
 	return nil
 }
 
-// SetInputVolume устанавливает громкость ввода (микрофон)
-func (a *App) SetInputVolume(level float32) error {
+// VolumeLimits - допустимый диапазон громкости устройства в положениях
+// слайдера (0.0 - 1.0). Отсутствующая граница громкость не ограничивает.
+type VolumeLimits struct {
+	Min *float32 `json:"min,omitempty"`
+	Max *float32 `json:"max,omitempty"`
+}
+
+// GetVolumeLimits возвращает диапазон громкости устройства
+func (a *App) GetVolumeLimits(deviceID string) VolumeLimits {
+	saved := a.deviceSettings(deviceID)
+	curve := a.volumeCurve(deviceID)
+	return VolumeLimits{Min: mapBound(saved.MinVolume, curve.FromDevice), Max: mapBound(saved.MaxVolume, curve.FromDevice)}
+}
+
+// mapBound переводит необязательную границу громкости функцией convert
+func mapBound(bound *float32, convert func(float32) float32) *float32 {
+	if bound == nil {
+		return nil
+	}
+	return ptrTo(convert(*bound))
+}
+
+// SetVolumeLimits задаёт диапазон громкости устройства; пустой диапазон
+// снимает ограничение. Громкость вне диапазона сразу возвращается в него.
+func (a *App) SetVolumeLimits(deviceID string, limits VolumeLimits) error {
+	if deviceID == "" {
+		return fmt.Errorf("no device")
+	}
+	for _, bound := range []*float32{limits.Min, limits.Max} {
+		if bound != nil && (*bound < 0 || *bound > 1) {
+			return fmt.Errorf("volume limit %.2f is out of range 0..1", *bound)
+		}
+	}
+	if limits.Min != nil && limits.Max != nil && *limits.Min > *limits.Max {
+		return fmt.Errorf("minimum volume %.2f is above maximum %.2f", *limits.Min, *limits.Max)
+	}
+
+	// Кривая монотонна, поэтому порядок границ сохраняется
+	curve := a.volumeCurve(deviceID)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.MinVolume = mapBound(limits.Min, curve.ToDevice)
+		dev.MaxVolume = mapBound(limits.Max, curve.ToDevice)
+	})
+
 	if a.audioManager == nil {
 		return nil
 	}
+	level, err := a.audioManager.GetDeviceVolume(deviceID)
+	if err != nil {
+		// Устройство может быть отключено: диапазон применится позже
+		return nil
+	}
+	if limited := a.deviceSettings(deviceID).LimitVolume(level); limited != level {
+		return a.rampVolume(a.audioManager, deviceID, limited)
+	}
+	return nil
+}
+
+// GetVolumeCap возвращает потолок громкости устройства в положении
+// слайдера (0.0 - 1.0); nil - потолка нет
+func (a *App) GetVolumeCap(deviceID string) *float32 {
+	return mapBound(a.deviceSettings(deviceID).VolumeCap, a.volumeCurve(deviceID).FromDevice)
+}
+
+// SetVolumeCap задаёт потолок громкости устройства; nil снимает его.
+// Громкость выше потолка сразу опускается до него.
+func (a *App) SetVolumeCap(deviceID string, volumeCap *float32) error {
+	if deviceID == "" {
+		return fmt.Errorf("no device")
+	}
+	if volumeCap != nil && (*volumeCap < 0 || *volumeCap > 1) {
+		return fmt.Errorf("volume cap %.2f is out of range 0..1", *volumeCap)
+	}
+
+	capLevel := mapBound(volumeCap, a.volumeCurve(deviceID).ToDevice)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.VolumeCap = capLevel
+	})
 
-	err := a.audioManager.SetDefaultInputVolume(level)
+	if a.audioManager == nil || capLevel == nil {
+		return nil
+	}
+	level, err := a.audioManager.GetDeviceVolume(deviceID)
+	if err != nil {
+		// Устройство может быть отключено: потолок применится позже
+		return nil
+	}
+	if level > *capLevel {
+		a.cancelRamp(deviceID)
+		return a.audioManager.SetDeviceVolume(deviceID, *capLevel)
+	}
+	return nil
+}
+
+// VolumeCapStatus - срабатывания потолка громкости для фронтенда.
+// Громкость - в положениях слайдера устройства.
+type VolumeCapStatus struct {
+	Interventions int64   `json:"interventions"` // с момента запуска
+	DeviceID      string  `json:"deviceId"`
+	Requested     float32 `json:"requested"` // громкость, которую пытались выставить
+	Cap           float32 `json:"cap"`
+	Time          int64   `json:"time"` // Unix-время в миллисекундах; 0 - не срабатывал
+}
+
+// GetVolumeCapStatus возвращает последнее срабатывание потолка громкости
+func (a *App) GetVolumeCapStatus() VolumeCapStatus {
+	a.capMu.Lock()
+	status := a.capStatus
+	a.capMu.Unlock()
+
+	if status.DeviceID != "" {
+		curve := a.volumeCurve(status.DeviceID)
+		status.Requested = curve.FromDevice(status.Requested)
+		status.Cap = curve.FromDevice(status.Cap)
+	}
+	return status
+}
+
+// recordCapIntervention запоминает срабатывание потолка громкости
+func (a *App) recordCapIntervention(deviceID string, requested, capLevel float32) {
+	a.capMu.Lock()
+	defer a.capMu.Unlock()
+
+	a.capStatus = VolumeCapStatus{
+		Interventions: a.capStatus.Interventions + 1,
+		DeviceID:      deviceID,
+		Requested:     requested,
+		Cap:           capLevel,
+		Time:          time.Now().UnixMilli(),
+	}
+}
+
+// VolumeRangeInfo - диапазон громкости устройства в децибелах для фронтенда
+type VolumeRangeInfo struct {
+	MinDB  float32 `json:"minDb"`
+	MaxDB  float32 `json:"maxDb"`
+	StepDB float32 `json:"stepDb"`
+}
+
+// GetVolumeRange возвращает диапазон громкости устройства в децибелах
+func (a *App) GetVolumeRange(deviceID string) (VolumeRangeInfo, error) {
+	if a.audioManager == nil {
+		return VolumeRangeInfo{}, fmt.Errorf("audio manager is not available")
+	}
+	r, err := a.audioManager.GetVolumeRange(deviceID)
+	if err != nil {
+		return VolumeRangeInfo{}, err
+	}
+	return VolumeRangeInfo{MinDB: r.MinDB, MaxDB: r.MaxDB, StepDB: r.StepDB}, nil
+}
+
+// GetDeviceVolumeDB возвращает громкость устройства в децибелах
+func (a *App) GetDeviceVolumeDB(deviceID string) (float32, error) {
+	if a.audioManager == nil {
+		return 0, fmt.Errorf("audio manager is not available")
+	}
+	return a.audioManager.GetDeviceVolumeDB(deviceID)
+}
+
+// SetDeviceVolumeDB устанавливает громкость устройства в децибелах и
+// запоминает её: при фиксации громкость удерживается именно в dB
+func (a *App) SetDeviceVolumeDB(deviceID string, db float32) error {
+	if a.audioManager == nil {
+		return fmt.Errorf("audio manager is not available")
+	}
+	// Значение читается сразу после записи, поэтому переход не нужен
+	a.cancelRamp(deviceID)
+	if err := a.audioManager.SetDeviceVolumeDB(deviceID, db); err != nil {
+		log.Printf("Failed to set volume in dB: %v", err)
+		return err
+	}
+
+	// Уровень в dB выше потолка опускается до потолка
+	if level, err := a.audioManager.GetDeviceVolume(deviceID); err == nil {
+		if capped := a.deviceSettings(deviceID).CapVolume(level); capped != level {
+			if err := a.audioManager.SetDeviceVolume(deviceID, capped); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Драйвер округляет до своего шага, поэтому запоминаем прочитанное значение
+	if actual, err := a.audioManager.GetDeviceVolumeDB(deviceID); err == nil {
+		db = actual
+	}
+	level, levelErr := a.audioManager.GetDeviceVolume(deviceID)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.VolumeDB = ptrTo(db)
+		if levelErr == nil {
+			dev.Volume = ptrTo(level)
+		}
+	})
+	return nil
+}
+
+// VolumeScalarToDB переводит положение слайдера (0.0 - 1.0) в децибелы
+// по кривой и диапазону устройства
+func (a *App) VolumeScalarToDB(deviceID string, scalar float32) (float32, error) {
+	if a.audioManager == nil {
+		return 0, fmt.Errorf("audio manager is not available")
+	}
+	r, err := a.audioManager.GetVolumeRange(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return r.ScalarToDB(a.volumeCurve(deviceID).ToDevice(scalar)), nil
+}
+
+// VolumeDBToScalar переводит децибелы в положение слайдера (0.0 - 1.0)
+func (a *App) VolumeDBToScalar(deviceID string, db float32) (float32, error) {
+	if a.audioManager == nil {
+		return 0, fmt.Errorf("audio manager is not available")
+	}
+	r, err := a.audioManager.GetVolumeRange(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return a.volumeCurve(deviceID).FromDevice(r.DBToScalar(db)), nil
+}
+
+// VolumeStepInfo - положение громкости в аппаратных шагах для фронтенда
+type VolumeStepInfo struct {
+	Step  uint32 `json:"step"`
+	Count uint32 `json:"count"`
+}
+
+// GetVolumeStepInfo возвращает текущий аппаратный шаг громкости и число шагов
+func (a *App) GetVolumeStepInfo(deviceID string) (VolumeStepInfo, error) {
+	if a.audioManager == nil {
+		return VolumeStepInfo{}, fmt.Errorf("audio manager is not available")
+	}
+	info, err := a.audioManager.GetVolumeStepInfo(deviceID)
+	if err != nil {
+		return VolumeStepInfo{}, err
+	}
+	return VolumeStepInfo{Step: info.Step, Count: info.Count}, nil
+}
+
+// VolumeStepUp увеличивает громкость устройства на один аппаратный шаг
+// и возвращает новую громкость
+func (a *App) VolumeStepUp(deviceID string) (float32, error) {
+	return a.changeVolume(deviceID, func() error {
+		return a.audioManager.VolumeStepUp(deviceID)
+	})
+}
+
+// VolumeStepDown уменьшает громкость устройства на один аппаратный шаг
+// и возвращает новую громкость
+func (a *App) VolumeStepDown(deviceID string) (float32, error) {
+	return a.changeVolume(deviceID, func() error {
+		return a.audioManager.VolumeStepDown(deviceID)
+	})
+}
+
+// AdjustVolume сдвигает слайдер устройства на percent процентов
+// (например, +5 или -10) и возвращает новое положение
+func (a *App) AdjustVolume(deviceID string, percent float32) (float32, error) {
+	curve := a.volumeCurve(deviceID)
+	return a.changeVolume(deviceID, func() error {
+		if curve.Kind == audio.CurveLinear {
+			_, err := a.audioManager.AdjustDeviceVolume(deviceID, percent/100)
+			return err
+		}
+		level, err := a.audioManager.GetDeviceVolume(deviceID)
+		if err != nil {
+			return err
+		}
+		return a.audioManager.SetDeviceVolume(deviceID, curve.ToDevice(curve.FromDevice(level)+percent/100))
+	})
+}
+
+// changeVolume выполняет относительное изменение громкости, удерживает
+// результат в диапазоне устройства и запоминает его. Запомненное значение -
+// это и зафиксированное, поэтому блокировка не откатит изменение.
+// Возвращает новое положение слайдера.
+func (a *App) changeVolume(deviceID string, change func() error) (float32, error) {
+	if a.audioManager == nil {
+		return 0, fmt.Errorf("audio manager is not available")
+	}
+	// Шаг отсчитывается от текущей громкости, а не от цели перехода
+	a.cancelRamp(deviceID)
+	if err := change(); err != nil {
+		log.Printf("Failed to change volume of %s: %v", deviceID, err)
+		return 0, err
+	}
+
+	level, err := a.audioManager.GetDeviceVolume(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	saved := a.deviceSettings(deviceID)
+	if limited := saved.CapVolume(saved.LimitVolume(level)); limited != level {
+		if err := a.audioManager.SetDeviceVolume(deviceID, limited); err != nil {
+			return 0, err
+		}
+		level = limited
+	}
+
+	db, dbErr := a.audioManager.GetDeviceVolumeDB(deviceID)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.Volume = ptrTo(level)
+		if dev.VolumeDB != nil && dbErr == nil {
+			dev.VolumeDB = ptrTo(db)
+		}
+	})
+	return a.volumeCurve(deviceID).FromDevice(level), nil
+}
+
+// VolumeCurveInfo - кривая слайдера устройства для фронтенда
+type VolumeCurveInfo struct {
+	Kind   string       `json:"kind"`             // linear, log или custom
+	Points [][2]float32 `json:"points,omitempty"` // [положение, громкость] для custom
+}
+
+// GetVolumeCurve возвращает кривую слайдера устройства
+func (a *App) GetVolumeCurve(deviceID string) VolumeCurveInfo {
+	saved := a.deviceSettings(deviceID)
+	return VolumeCurveInfo{Kind: a.volumeCurve(deviceID).Kind.String(), Points: saved.CurvePoints}
+}
+
+// SetVolumeCurve задаёт кривую слайдера устройства. Громкость устройства
+// не меняется - меняется только её положение на слайдере.
+func (a *App) SetVolumeCurve(deviceID string, curve VolumeCurveInfo) error {
+	if deviceID == "" {
+		return fmt.Errorf("no device")
+	}
+	parsed, err := audio.NewVolumeCurve(curve.Kind, curve.Points)
 	if err != nil {
-		log.Printf("Failed to set input volume: %v", err)
 		return err
 	}
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.Curve = ""
+		dev.CurvePoints = nil
+		if parsed.Kind != audio.CurveLinear {
+			dev.Curve = parsed.Kind.String()
+		}
+		if parsed.Kind == audio.CurveCustom {
+			dev.CurvePoints = curve.Points
+		}
+	})
+	return nil
+}
+
+// volumeCurve возвращает кривую слайдера устройства; испорченная
+// в файле настроек кривая заменяется линейной
+func (a *App) volumeCurve(deviceID string) audio.VolumeCurve {
+	saved := a.deviceSettings(deviceID)
+	curve, err := audio.NewVolumeCurve(saved.Curve, saved.CurvePoints)
+	if err != nil {
+		log.Printf("Invalid volume curve of %s, using linear: %v", deviceID, err)
+		return audio.VolumeCurve{Kind: audio.CurveLinear}
+	}
+	return curve
+}
 
-	a.settings.InputVolume = level
-	a.settingsManager.Save(a.settings)
+// VolumeRampInfo - параметры плавного изменения громкости для фронтенда
+type VolumeRampInfo struct {
+	DurationMs int    `json:"durationMs"`
+	Curve      string `json:"curve"` // linear или exponential
+}
+
+// maxVolumeRampMs - самый долгий переход, который можно настроить
+const maxVolumeRampMs = 10000
+
+// GetVolumeRamp возвращает параметры плавного изменения громкости
+func (a *App) GetVolumeRamp() VolumeRampInfo {
+	ramp := a.volumeRamp()
+	return VolumeRampInfo{DurationMs: int(ramp.Duration / time.Millisecond), Curve: ramp.Curve.String()}
+}
+
+// SetVolumeRamp задаёт длительность и кривую перехода; длительность 0
+// возвращает мгновенное изменение громкости
+func (a *App) SetVolumeRamp(ramp VolumeRampInfo) error {
+	if ramp.DurationMs < 0 || ramp.DurationMs > maxVolumeRampMs {
+		return fmt.Errorf("ramp duration %d ms is out of range 0..%d", ramp.DurationMs, maxVolumeRampMs)
+	}
+	if _, ok := audio.ParseRampCurve(ramp.Curve); !ok {
+		return fmt.Errorf("unknown ramp curve %q", ramp.Curve)
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.VolumeRampMs = ramp.DurationMs
+		s.VolumeRampCurve = ramp.Curve
+	})
 	return nil
 }
 
+// volumeRamp возвращает настроенный переход. Его запрашивают привязки
+// и горутина уведомлений, поэтому настройки читаются под settingsMu.
+func (a *App) volumeRamp() audio.Ramp {
+	current := a.currentSettings()
+	curve, ok := audio.ParseRampCurve(current.VolumeRampCurve)
+	if !ok {
+		curve = audio.RampLinear
+	}
+	return audio.Ramp{
+		Duration: time.Duration(current.VolumeRampMs) * time.Millisecond,
+		Curve:    curve,
+	}
+}
+
+// rampVolume переводит громкость устройства к level с настроенным
+// переходом; без Ramper записывает её сразу через audioMgr.
+// Громкость выше потолка устройства не выставляется.
+func (a *App) rampVolume(audioMgr audio.Backend, deviceID string, level float32) error {
+	level = a.deviceSettings(deviceID).CapVolume(level)
+	if a.ramper == nil {
+		return audioMgr.SetDeviceVolume(deviceID, level)
+	}
+	return a.ramper.SetVolume(deviceID, level, a.volumeRamp())
+}
+
+// rampVolumeDB - то же для громкости в децибелах. Скаляр, который даст
+// уровень в dB, заранее неизвестен, поэтому при потолке громкость
+// выставляется сразу и потолок проверяет уже записанное значение.
+func (a *App) rampVolumeDB(audioMgr audio.Backend, deviceID string, db float32) error {
+	if a.ramper == nil || a.deviceSettings(deviceID).VolumeCap != nil {
+		a.cancelRamp(deviceID)
+		return audioMgr.SetDeviceVolumeDB(deviceID, db)
+	}
+	return a.ramper.SetVolumeDB(deviceID, db, a.volumeRamp())
+}
+
+// cancelRamp останавливает переход перед прямой записью громкости,
+// иначе он перезапишет её на следующем шаге
+func (a *App) cancelRamp(deviceID string) {
+	if a.ramper != nil {
+		a.ramper.Cancel(deviceID)
+	}
+}
+
+// GetChannelVolumes возвращает громкость каждого канала устройства
+func (a *App) GetChannelVolumes(deviceID string) ([]float32, error) {
+	if a.audioManager == nil {
+		return nil, fmt.Errorf("audio manager is not available")
+	}
+	return a.audioManager.GetChannelVolumes(deviceID)
+}
+
+// SetChannelVolumes устанавливает громкость каналов и запоминает её для устройства
+func (a *App) SetChannelVolumes(deviceID string, levels []float32) error {
+	if a.audioManager == nil {
+		return fmt.Errorf("audio manager is not available")
+	}
+	if err := a.audioManager.SetChannelVolumes(deviceID, levels); err != nil {
+		log.Printf("Failed to set channel volumes: %v", err)
+		return err
+	}
+	a.rememberChannels(deviceID)
+	return nil
+}
+
+// GetBalance возвращает баланс устройства: -1 - только левый канал,
+// 0 - по центру, 1 - только правый
+func (a *App) GetBalance(deviceID string) (float32, error) {
+	channels, err := a.GetChannelVolumes(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return audio.Balance(channels), nil
+}
+
+// SetBalance смещает баланс устройства, не меняя общую громкость,
+// и запоминает получившиеся уровни каналов
+func (a *App) SetBalance(deviceID string, balance float32) error {
+	channels, err := a.GetChannelVolumes(deviceID)
+	if err != nil {
+		return err
+	}
+	if len(channels) < 2 {
+		return fmt.Errorf("device has %d channel(s), balance needs two", len(channels))
+	}
+	master, err := a.audioManager.GetDeviceVolume(deviceID)
+	if err != nil {
+		return err
+	}
+	return a.SetChannelVolumes(deviceID, audio.ChannelsForBalance(master, balance, len(channels)))
+}
+
+// GetLockBalance возвращает состояние фиксации баланса
+func (a *App) GetLockBalance() bool {
+	return a.currentSettings().LockBalance
+}
+
+// SetLockBalance включает фиксацию баланса. Фиксируется текущий баланс
+// устройств по умолчанию, для которых он ещё не запомнен.
+func (a *App) SetLockBalance(enabled bool) {
+	if enabled && a.audioManager != nil {
+		for _, dataFlow := range dataFlows {
+			deviceID := a.audioManager.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+			if deviceID != "" && a.deviceSettings(deviceID).Channels == nil {
+				a.rememberChannels(deviceID)
+			}
+		}
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.LockBalance = enabled
+	})
+}
+
+// rememberChannels запоминает текущие уровни каналов устройства
+func (a *App) rememberChannels(deviceID string) {
+	channels, err := a.audioManager.GetChannelVolumes(deviceID)
+	if err != nil {
+		return
+	}
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.Channels = channels
+	})
+}
+
 // GetLockVolume возвращает состояние блокировки громкости
 func (a *App) GetLockVolume() bool {
-	return a.settings.LockVolume
+	return a.currentSettings().LockVolume
 }
 
-// SetLockVolume устанавливает блокировку громкости
+// SetLockVolume устанавливает блокировку громкости. Фиксируется текущее
+// состояние устройств по умолчанию: громкость (в том числе 0%) и звук.
 func (a *App) SetLockVolume(enabled bool) {
-	a.settings.LockVolume = enabled
-	a.settingsManager.Save(a.settings)
+	if enabled {
+		a.captureVolumeState()
+		a.captureMuteState()
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.LockVolume = enabled
+	})
+}
+
+// ToggleOutputMute выключает или включает звук устройства вывода по умолчанию
+// и возвращает новое состояние
+func (a *App) ToggleOutputMute() (bool, error) {
+	muted, err := a.toggleMute(audio.ERender)
+	if err != nil {
+		log.Printf("Failed to toggle output mute: %v", err)
+	}
+	return muted, err
+}
+
+// ToggleInputMute выключает или включает микрофон по умолчанию
+// и возвращает новое состояние
+func (a *App) ToggleInputMute() (bool, error) {
+	muted, err := a.toggleMute(audio.ECapture)
+	if err != nil {
+		log.Printf("Failed to toggle input mute: %v", err)
+	}
+	return muted, err
+}
+
+// toggleMute инвертирует состояние звука устройства по умолчанию
+// и запоминает его для устройства
+func (a *App) toggleMute(dataFlow audio.EDataFlow) (bool, error) {
+	if a.audioManager == nil {
+		return false, fmt.Errorf("audio manager is not available")
+	}
+	deviceID := a.audioManager.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+	if deviceID == "" {
+		return false, fmt.Errorf("no default device")
+	}
+
+	muted, err := a.audioManager.GetDeviceMute(deviceID)
+	if err != nil {
+		return false, err
+	}
+	if err := a.audioManager.SetDeviceMute(deviceID, !muted); err != nil {
+		return false, err
+	}
+
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.Muted = ptrTo(!muted)
+	})
+	return !muted, nil
+}
+
+// GetLockMute возвращает состояние блокировки выключенного звука
+func (a *App) GetLockMute() bool {
+	return a.currentSettings().LockMute
+}
+
+// SetLockMute включает блокировку: выключенный через AutoSound звук
+// остаётся выключенным, даже если другое приложение его включит
+func (a *App) SetLockMute(enabled bool) {
+	// Фиксируем текущее состояние, а не то, что было сохранено когда-то
+	if enabled {
+		a.captureMuteState()
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.LockMute = enabled
+	})
+}
+
+// captureVolumeState запоминает текущую громкость устройств по умолчанию
+func (a *App) captureVolumeState() {
+	if a.audioManager == nil {
+		return
+	}
+	for _, dataFlow := range dataFlows {
+		deviceID := a.audioManager.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		level, err := a.audioManager.GetDeviceVolume(deviceID)
+		if err != nil {
+			continue
+		}
+		db, dbErr := a.audioManager.GetDeviceVolumeDB(deviceID)
+		a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+			dev.Volume = ptrTo(level)
+			// Громкость, заданная в dB, и фиксируется в dB
+			if dev.VolumeDB != nil && dbErr == nil {
+				dev.VolumeDB = ptrTo(db)
+			}
+		})
+	}
+}
+
+// captureMuteState запоминает, выключен ли звук устройств по умолчанию
+func (a *App) captureMuteState() {
+	if a.audioManager == nil {
+		return
+	}
+	for _, dataFlow := range dataFlows {
+		deviceID := a.audioManager.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		if muted, err := a.audioManager.GetDeviceMute(deviceID); err == nil {
+			a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+				dev.Muted = ptrTo(muted)
+			})
+		}
+	}
+}
+
+// deviceSettings возвращает копию запомненных настроек устройства.
+// Настройки устройств меняет и горутина уведомлений, поэтому доступ к ним
+// идёт под settingsMu.
+func (a *App) deviceSettings(deviceID string) settings.DeviceSettings {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return a.settings.Device(deviceID)
+}
+
+// currentSettings возвращает копию общих настроек. Флаги меняют привязки,
+// а читает горутина уведомлений, поэтому копия берётся под settingsMu.
+// Настройки устройств из копии не читаются: для них есть deviceSettings.
+func (a *App) currentSettings() settings.Settings {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return *a.settings
+}
+
+// updateSettings изменяет общие настройки под settingsMu и сохраняет их
+func (a *App) updateSettings(update func(s *settings.Settings)) {
+	a.settingsMu.Lock()
+	update(a.settings)
+	a.settingsMu.Unlock()
+
+	a.saveSettings()
+}
+
+// savedSelection возвращает сохранённый выбор устройств направления.
+// Выбор заменяют привязки, а читает горутина уведомлений, поэтому он
+// берётся под settingsMu. Срезы и карты выбора при замене не меняются
+// на месте, так что снимок можно читать и после снятия блокировки.
+func (a *App) savedSelection(dataFlow audio.EDataFlow) deviceSelection {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	if dataFlow == audio.ECapture {
+		return deviceSelection{a.settings.InputPriority, a.settings.InputRoleDevices}
+	}
+	return deviceSelection{a.settings.OutputPriority, a.settings.OutputRoleDevices}
+}
+
+// updateDevice изменяет запомненные настройки устройства и сохраняет их
+func (a *App) updateDevice(deviceID string, update func(dev *settings.DeviceSettings)) {
+	if deviceID == "" {
+		return
+	}
+	a.settingsMu.Lock()
+	update(a.settings.EnsureDevice(deviceID))
+	a.settingsMu.Unlock()
+
+	a.saveSettings()
+}
+
+// saveSettings сохраняет настройки в файл
+func (a *App) saveSettings() {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+
+	if err := a.settingsManager.Save(a.settings); err != nil {
+		log.Printf("Failed to save settings: %v", err)
+	}
 }
 
 // GetAutostartEnabled возвращает состояние автозапуска
@@ -346,71 +1541,5 @@ func (a *App) ShouldShowAutostartPrompt() bool {
 // MarkAutostartAsked помечает что пользователя спросили об автозапуске
 func (a *App) MarkAutostartAsked() {
 	a.settings.AutostartAsked = true
-	a.settingsManager.Save(a.settings)
-}
-
-func (a *App) startDeviceNotifier() {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	audioMgr, err := audio.NewAudioManager()
-	if err != nil {
-		log.Printf("Failed to create audio manager for notifier: %v", err)
-		return
-	}
-	defer audioMgr.Close()
-
-	ticker := time.NewTicker(2 * time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-a.stopNotifier:
-			return
-		case <-ticker.C:
-			// Проверяем устройства (если включено автопереключение)
-			if a.settings.AutoSwitch {
-				// Проверяем устройство вывода
-				currentOutputID := audioMgr.GetCurrentDefaultOutputID()
-				if a.settings.OutputDeviceID != "" && currentOutputID != a.settings.OutputDeviceID {
-					log.Printf("Output device changed externally, restoring...")
-					audioMgr.SetDefaultDevice(a.settings.OutputDeviceID)
-				}
-
-				// Проверяем устройство ввода
-				currentInputID := audioMgr.GetCurrentDefaultInputID()
-				if a.settings.InputDeviceID != "" && currentInputID != a.settings.InputDeviceID {
-					log.Printf("Input device changed externally, restoring...")
-					audioMgr.SetDefaultDevice(a.settings.InputDeviceID)
-				}
-			}
-
-			// Проверяем громкость (если включена блокировка)
-			if a.settings.LockVolume {
-				// Проверяем громкость вывода
-				if a.settings.OutputVolume > 0 {
-					currentOutputVol, err := audioMgr.GetDefaultOutputVolume()
-					if err == nil {
-						diff := currentOutputVol - a.settings.OutputVolume
-						if diff < -0.01 || diff > 0.01 {
-							log.Printf("Output volume changed externally (%.2f -> %.2f), restoring...", currentOutputVol, a.settings.OutputVolume)
-							audioMgr.SetDefaultOutputVolume(a.settings.OutputVolume)
-						}
-					}
-				}
-
-				// Проверяем громкость ввода
-				if a.settings.InputVolume > 0 {
-					currentInputVol, err := audioMgr.GetDefaultInputVolume()
-					if err == nil {
-						diff := currentInputVol - a.settings.InputVolume
-						if diff < -0.01 || diff > 0.01 {
-							log.Printf("Input volume changed externally (%.2f -> %.2f), restoring...", currentInputVol, a.settings.InputVolume)
-							audioMgr.SetDefaultInputVolume(a.settings.InputVolume)
-						}
-					}
-				}
-			}
-		}
-	}
+	a.saveSettings()
 }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+// newTestApp создает App с FakeBackend и настройками во временном каталоге,
+// как это делает startup, но без окна и трея
+func newTestApp(t *testing.T) (*App, *audio.FakeBackend, string) {
+	t.Helper()
+
+	fake := audio.NewFakeBackend()
+	fake.AddDevice("speakers", "Speakers", audio.ERender, 0.5)
+	fake.AddDevice("headphones", "Headphones", audio.ERender, 0.25)
+	fake.AddDevice("mic", "Microphone", audio.ECapture, 0.75)
+
+	dir := t.TempDir()
+	a := newTestAppInDir(t, fake, dir)
+	return a, fake, dir
+}
+
+// newTestAppInDir создает App, загружающий настройки из dir
+func newTestAppInDir(t *testing.T, fake *audio.FakeBackend, dir string) *App {
+	t.Helper()
+
+	a := NewApp()
+	a.newBackend = func() (audio.Backend, error) { return fake, nil }
+
+	var err error
+	if a.settingsManager, err = settings.NewSettingsManagerInDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if a.settings, err = a.settingsManager.Load(); err != nil {
+		t.Fatal(err)
+	}
+	a.ResetChanges()
+	a.audioManager = fake
+	return a
+}
+
+func TestSaveSettingsAppliesAndPersistsSelection(t *testing.T) {
+	a, fake, dir := newTestApp(t)
+
+	a.SelectOutputDevice("headphones")
+	if !a.HasUnsavedChanges() {
+		t.Fatal("selection is not reported as unsaved")
+	}
+	if err := a.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if a.HasUnsavedChanges() {
+		t.Error("selection is still unsaved after SaveSettings")
+	}
+
+	for _, role := range audio.Roles {
+		if got := fake.GetDefaultDeviceID(audio.ERender, role); got != "headphones" {
+			t.Errorf("default %s device = %q, want headphones", role, got)
+		}
+	}
+
+	// Новый экземпляр читает тот же файл настроек
+	reloaded := newTestAppInDir(t, fake, dir)
+	if got := reloaded.settings.OutputPriority; !slices.Equal(got, []string{"headphones"}) {
+		t.Errorf("saved output priority = %v, want [headphones]", got)
+	}
+	if got := reloaded.settings.OutputDeviceID; got != "headphones" {
+		t.Errorf("saved output device = %q, want headphones", got)
+	}
+	if got := reloaded.GetOutputPriority(); !slices.Equal(got, []string{"headphones"}) {
+		t.Errorf("pending priority after reload = %v, want [headphones]", got)
+	}
+}
+
+func TestSaveSettingsKeepsFallbackOrder(t *testing.T) {
+	a, _, dir := newTestApp(t)
+
+	a.SelectOutputDevice("speakers")
+	a.SelectOutputDevice("headphones")
+	if err := a.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := newTestAppInDir(t, audio.NewFakeBackend(), dir)
+	want := []string{"headphones", "speakers"}
+	if got := reloaded.settings.OutputPriority; !slices.Equal(got, want) {
+		t.Errorf("saved output priority = %v, want %v", got, want)
+	}
+}
+
+func TestResetChangesDropsPendingSelection(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+
+	a.SelectOutputDevice("headphones")
+	a.ResetChanges()
+	if a.HasUnsavedChanges() {
+		t.Error("selection is unsaved after ResetChanges")
+	}
+	if err := a.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.GetCurrentDefaultOutputID(); got != "speakers" {
+		t.Errorf("default output = %q, want speakers", got)
+	}
+}
+
+func TestGetVolumes(t *testing.T) {
+	a, fake, dir := newTestApp(t)
+
+	info := a.GetVolumes()
+	if info.OutputDeviceID != "speakers" || info.InputDeviceID != "mic" {
+		t.Fatalf("devices = %q/%q, want speakers/mic", info.OutputDeviceID, info.InputDeviceID)
+	}
+	if info.OutputVolume != 0.5 || info.InputVolume != 0.75 {
+		t.Errorf("volumes = %v/%v, want 0.5/0.75", info.OutputVolume, info.InputVolume)
+	}
+	if info.OutputSaved != nil || info.InputSaved != nil {
+		t.Errorf("saved volumes = %v/%v, want none", info.OutputSaved, info.InputSaved)
+	}
+	if info.LockVolume {
+		t.Error("volume lock is on by default")
+	}
+
+	// Фиксация запоминает текущую громкость и переживает перезапуск
+	a.SetLockVolume(true)
+	fake.SetExternalMute("speakers", true)
+
+	reloaded := newTestAppInDir(t, fake, dir)
+	info = reloaded.GetVolumes()
+	if !info.LockVolume {
+		t.Error("volume lock is not persisted")
+	}
+	if info.OutputSaved == nil || *info.OutputSaved != 0.5 {
+		t.Errorf("saved output volume = %v, want 0.5", info.OutputSaved)
+	}
+	if info.InputSaved == nil || *info.InputSaved != 0.75 {
+		t.Errorf("saved input volume = %v, want 0.75", info.InputSaved)
+	}
+	if !info.OutputMuted {
+		t.Error("output mute is not reported")
+	}
+}
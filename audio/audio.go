@@ -1,3 +1,5 @@
+//go:build windows
+
 package audio
 
 import (
@@ -16,42 +18,6 @@ var (
 	CLSID_PolicyConfigClient = ole.NewGUID("{870AF99C-171D-4F9E-AF0D-E63DF40C2BC9}")
 )
 
-// EDataFlow - направление потока данных
-type EDataFlow uint32
-
-const (
-	ERender  EDataFlow = 0 // Устройства вывода (колонки, наушники)
-	ECapture EDataFlow = 1 // Устройства ввода (микрофоны)
-	EAll     EDataFlow = 2
-)
-
-// ERole - роль устройства
-type ERole uint32
-
-const (
-	EConsole       ERole = 0
-	EMultimedia    ERole = 1
-	ECommunication ERole = 2
-)
-
-// DEVICE_STATE константы
-const (
-	DEVICE_STATE_ACTIVE     = 0x00000001
-	DEVICE_STATE_DISABLED   = 0x00000002
-	DEVICE_STATE_NOTPRESENT = 0x00000004
-	DEVICE_STATE_UNPLUGGED  = 0x00000008
-	DEVICE_STATEMASK_ALL    = 0x0000000F
-)
-
-// AudioDevice представляет аудиоустройство
-type AudioDevice struct {
-	ID           string
-	Name         string
-	IsDefault    bool
-	DataFlow     EDataFlow
-	FriendlyName string
-}
-
 // IID для IAudioEndpointVolume
 var (
 	IID_IAudioEndpointVolume = ole.NewGUID("{5CDF2C82-841E-4546-9722-0CF74078229A}")
@@ -109,26 +75,6 @@ type IPropertyStoreVtbl struct {
 	Commit   uintptr
 }
 
-// PROPERTYKEY структура
-type PROPERTYKEY struct {
-	Fmtid ole.GUID
-	Pid   uint32
-}
-
-// PROPVARIANT структура (упрощенная)
-type PROPVARIANT struct {
-	Vt       uint16
-	Reserved [6]byte
-	Val      uint64
-}
-
-var (
-	PKEY_Device_FriendlyName = PROPERTYKEY{
-		Fmtid: *ole.NewGUID("{A45C254E-DF1C-4EFD-8020-67D146A850E0}"),
-		Pid:   14,
-	}
-)
-
 // IAudioEndpointVolume интерфейс для управления громкостью
 type IAudioEndpointVolume struct {
 	ole.IUnknown
@@ -179,9 +125,14 @@ type IPolicyConfigVtbl struct {
 
 // AudioManager управляет аудиоустройствами
 type AudioManager struct {
-	enumerator *IMMDeviceEnumerator
+	enumerator    *IMMDeviceEnumerator
+	notifier      *notificationClient
+	volumeEvents  chan VolumeEvent
+	volumeWatches map[string]*volumeRegistration
 }
 
+var _ Backend = (*AudioManager)(nil)
+
 var (
 	modole32             = syscall.NewLazyDLL("ole32.dll")
 	procCoCreateInstance = modole32.NewProc("CoCreateInstance")
@@ -206,6 +157,15 @@ func coCreateInstance(clsid *ole.GUID, iid *ole.GUID, ppv *unsafe.Pointer) error
 	return nil
 }
 
+// NewBackend создает COM-реализацию Backend
+func NewBackend() (Backend, error) {
+	am, err := NewAudioManager()
+	if err != nil {
+		return nil, err
+	}
+	return am, nil
+}
+
 // NewAudioManager создает новый менеджер аудио
 func NewAudioManager() (*AudioManager, error) {
 	err := ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED)
@@ -223,28 +183,37 @@ func NewAudioManager() (*AudioManager, error) {
 		return nil, err
 	}
 
-	return &AudioManager{enumerator: enumerator}, nil
+	return &AudioManager{
+		enumerator:    enumerator,
+		volumeEvents:  make(chan VolumeEvent, volumeEventBuffer),
+		volumeWatches: make(map[string]*volumeRegistration),
+	}, nil
 }
 
 // Close освобождает ресурсы
 func (am *AudioManager) Close() {
 	if am.enumerator != nil {
+		am.unwatchDevices()
+		for deviceID := range am.volumeWatches {
+			am.UnwatchVolume(deviceID)
+		}
 		am.enumerator.Release()
 	}
 	ole.CoUninitialize()
 }
 
-// GetOutputDevices возвращает список устройств вывода
+// GetOutputDevices возвращает список активных устройств вывода
 func (am *AudioManager) GetOutputDevices() ([]AudioDevice, error) {
-	return am.getDevices(ERender)
+	return am.GetDevices(ERender, DEVICE_STATE_ACTIVE)
 }
 
-// GetInputDevices возвращает список устройств ввода
+// GetInputDevices возвращает список активных устройств ввода
 func (am *AudioManager) GetInputDevices() ([]AudioDevice, error) {
-	return am.getDevices(ECapture)
+	return am.GetDevices(ECapture, DEVICE_STATE_ACTIVE)
 }
 
-func (am *AudioManager) getDevices(dataFlow EDataFlow) ([]AudioDevice, error) {
+// GetDevices возвращает устройства направления, состояние которых входит в stateMask
+func (am *AudioManager) GetDevices(dataFlow EDataFlow, stateMask uint32) ([]AudioDevice, error) {
 	vtbl := (*IMMDeviceEnumeratorVtbl)(unsafe.Pointer(am.enumerator.RawVTable))
 
 	var collection *IMMDeviceCollection
@@ -252,7 +221,7 @@ func (am *AudioManager) getDevices(dataFlow EDataFlow) ([]AudioDevice, error) {
 		vtbl.EnumAudioEndpoints,
 		uintptr(unsafe.Pointer(am.enumerator)),
 		uintptr(dataFlow),
-		uintptr(DEVICE_STATE_ACTIVE),
+		uintptr(stateMask),
 		uintptr(unsafe.Pointer(&collection)),
 	)
 	if hr != 0 {
@@ -260,8 +229,11 @@ func (am *AudioManager) getDevices(dataFlow EDataFlow) ([]AudioDevice, error) {
 	}
 	defer collection.Release()
 
-	// Получаем устройство по умолчанию
-	defaultDeviceID := am.getDefaultDeviceID(dataFlow)
+	// Получаем устройства по умолчанию для каждой роли
+	defaultIDs := make(map[ERole]string, len(Roles))
+	for _, role := range Roles {
+		defaultIDs[role] = am.GetDefaultDeviceID(dataFlow, role)
+	}
 
 	// Получаем количество устройств
 	collVtbl := (*IMMDeviceCollectionVtbl)(unsafe.Pointer(collection.RawVTable))
@@ -289,14 +261,17 @@ func (am *AudioManager) getDevices(dataFlow EDataFlow) ([]AudioDevice, error) {
 		}
 
 		deviceID := am.getDeviceID(device)
-		deviceName := am.getDeviceName(device)
+		deviceName, props := am.getDeviceInfo(device)
 
 		devices = append(devices, AudioDevice{
-			ID:           deviceID,
-			Name:         deviceName,
-			FriendlyName: deviceName,
-			IsDefault:    deviceID == defaultDeviceID,
-			DataFlow:     dataFlow,
+			ID:               deviceID,
+			Name:             deviceName,
+			FriendlyName:     deviceName,
+			DeviceProperties: props,
+			IsDefault:        deviceID == defaultIDs[EMultimedia],
+			DefaultRoles:     defaultRolesOf(deviceID, defaultIDs),
+			DataFlow:         dataFlow,
+			State:            am.getDeviceState(device),
 		})
 
 		device.Release()
@@ -305,7 +280,8 @@ func (am *AudioManager) getDevices(dataFlow EDataFlow) ([]AudioDevice, error) {
 	return devices, nil
 }
 
-func (am *AudioManager) getDefaultDeviceID(dataFlow EDataFlow) string {
+// GetDefaultDeviceID возвращает ID устройства по умолчанию для роли
+func (am *AudioManager) GetDefaultDeviceID(dataFlow EDataFlow, role ERole) string {
 	vtbl := (*IMMDeviceEnumeratorVtbl)(unsafe.Pointer(am.enumerator.RawVTable))
 
 	var device *IMMDevice
@@ -313,7 +289,7 @@ func (am *AudioManager) getDefaultDeviceID(dataFlow EDataFlow) string {
 		vtbl.GetDefaultAudioEndpoint,
 		uintptr(unsafe.Pointer(am.enumerator)),
 		uintptr(dataFlow),
-		uintptr(EMultimedia),
+		uintptr(role),
 		uintptr(unsafe.Pointer(&device)),
 	)
 	if hr != 0 {
@@ -341,44 +317,33 @@ func (am *AudioManager) getDeviceID(device *IMMDevice) string {
 	return utf16PtrToString(pwstrID)
 }
 
-func (am *AudioManager) getDeviceName(device *IMMDevice) string {
+func (am *AudioManager) getDeviceState(device *IMMDevice) uint32 {
 	vtbl := (*IMMDeviceVtbl)(unsafe.Pointer(device.RawVTable))
 
-	var propStore *IPropertyStore
+	var state uint32
 	hr, _, _ := syscall.SyscallN(
-		vtbl.OpenPropertyStore,
+		vtbl.GetState,
 		uintptr(unsafe.Pointer(device)),
-		uintptr(0), // STGM_READ
-		uintptr(unsafe.Pointer(&propStore)),
+		uintptr(unsafe.Pointer(&state)),
 	)
 	if hr != 0 {
-		return "Unknown Device"
+		return 0
 	}
-	defer propStore.Release()
 
-	propVtbl := (*IPropertyStoreVtbl)(unsafe.Pointer(propStore.RawVTable))
-
-	var propVar PROPVARIANT
-	hr, _, _ = syscall.SyscallN(
-		propVtbl.GetValue,
-		uintptr(unsafe.Pointer(propStore)),
-		uintptr(unsafe.Pointer(&PKEY_Device_FriendlyName)),
-		uintptr(unsafe.Pointer(&propVar)),
-	)
-	if hr != 0 {
-		return "Unknown Device"
-	}
+	return state
+}
 
-	if propVar.Vt == 31 { // VT_LPWSTR
-		ptr := (*uint16)(unsafe.Pointer(uintptr(propVar.Val)))
-		return utf16PtrToString(ptr)
-	}
+// SetDefaultDevice устанавливает устройство по умолчанию для всех ролей
+func (am *AudioManager) SetDefaultDevice(deviceID string) error {
+	return am.setDefaultEndpoint(deviceID, Roles)
+}
 
-	return "Unknown Device"
+// SetDefaultDeviceForRole устанавливает устройство по умолчанию для одной роли
+func (am *AudioManager) SetDefaultDeviceForRole(deviceID string, role ERole) error {
+	return am.setDefaultEndpoint(deviceID, []ERole{role})
 }
 
-// SetDefaultDevice устанавливает устройство по умолчанию
-func (am *AudioManager) SetDefaultDevice(deviceID string) error {
+func (am *AudioManager) setDefaultEndpoint(deviceID string, roles []ERole) error {
 	var policyConfig *IPolicyConfig
 
 	err := coCreateInstance(CLSID_PolicyConfigClient, IID_IPolicyConfig, (*unsafe.Pointer)(unsafe.Pointer(&policyConfig)))
@@ -394,8 +359,6 @@ func (am *AudioManager) SetDefaultDevice(deviceID string) error {
 		return err
 	}
 
-	// Устанавливаем для всех ролей
-	roles := []ERole{EConsole, EMultimedia, ECommunication}
 	for _, role := range roles {
 		hr, _, _ := syscall.SyscallN(
 			vtbl.SetDefaultEndpoint,
@@ -413,12 +376,12 @@ func (am *AudioManager) SetDefaultDevice(deviceID string) error {
 
 // GetCurrentDefaultOutputID возвращает ID текущего устройства вывода по умолчанию
 func (am *AudioManager) GetCurrentDefaultOutputID() string {
-	return am.getDefaultDeviceID(ERender)
+	return am.GetDefaultDeviceID(ERender, EMultimedia)
 }
 
 // GetCurrentDefaultInputID возвращает ID текущего устройства ввода по умолчанию
 func (am *AudioManager) GetCurrentDefaultInputID() string {
-	return am.getDefaultDeviceID(ECapture)
+	return am.GetDefaultDeviceID(ECapture, EMultimedia)
 }
 
 func utf16PtrToString(ptr *uint16) string {
@@ -437,68 +400,278 @@ func utf16PtrToString(ptr *uint16) string {
 
 // GetDeviceVolume возвращает громкость устройства (0.0 - 1.0)
 func (am *AudioManager) GetDeviceVolume(deviceID string) (float32, error) {
-	device, err := am.getDeviceByID(deviceID)
-	if err != nil {
-		return 0, err
-	}
-	defer device.Release()
+	var level float32
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetMasterVolumeLevelScalar,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&level)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get volume level: %x", hr)
+		}
+		return nil
+	})
+	return level, err
+}
 
-	volume, err := am.getEndpointVolume(device)
-	if err != nil {
-		return 0, err
-	}
-	defer volume.Release()
+// SetDeviceVolume устанавливает громкость устройства (0.0 - 1.0)
+func (am *AudioManager) SetDeviceVolume(deviceID string, level float32) error {
+	// Ограничиваем значение
+	level = clampVolume(level)
 
-	vtbl := (*IAudioEndpointVolumeVtbl)(unsafe.Pointer(volume.RawVTable))
+	return am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.SetMasterVolumeLevelScalar,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(*(*uint32)(unsafe.Pointer(&level))),
+			uintptr(unsafe.Pointer(EventContext)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to set volume level: %x", hr)
+		}
+		return nil
+	})
+}
 
+// AdjustDeviceVolume меняет громкость на delta и возвращает новую громкость
+func (am *AudioManager) AdjustDeviceVolume(deviceID string, delta float32) (float32, error) {
 	var level float32
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetMasterVolumeLevelScalar,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&level)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get volume level: %x", hr)
+		}
+
+		level = clampVolume(level + delta)
+		hr, _, _ = syscall.SyscallN(
+			vtbl.SetMasterVolumeLevelScalar,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(*(*uint32)(unsafe.Pointer(&level))),
+			uintptr(unsafe.Pointer(EventContext)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to set volume level: %x", hr)
+		}
+		return nil
+	})
+	return level, err
+}
+
+// GetVolumeStepInfo возвращает текущий шаг громкости и число шагов
+func (am *AudioManager) GetVolumeStepInfo(deviceID string) (VolumeStepInfo, error) {
+	var info VolumeStepInfo
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetVolumeStepInfo,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&info.Step)),
+			uintptr(unsafe.Pointer(&info.Count)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get volume step info: %x", hr)
+		}
+		return nil
+	})
+	return info, err
+}
+
+// VolumeStepUp увеличивает громкость на один аппаратный шаг
+func (am *AudioManager) VolumeStepUp(deviceID string) error {
+	return am.volumeStep(deviceID, true)
+}
+
+// VolumeStepDown уменьшает громкость на один аппаратный шаг
+func (am *AudioManager) VolumeStepDown(deviceID string) error {
+	return am.volumeStep(deviceID, false)
+}
+
+func (am *AudioManager) volumeStep(deviceID string, up bool) error {
+	return am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		method := vtbl.VolumeStepDown
+		if up {
+			method = vtbl.VolumeStepUp
+		}
+		hr, _, _ := syscall.SyscallN(
+			method,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(EventContext)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to step volume: %x", hr)
+		}
+		return nil
+	})
+}
+
+// GetChannelVolumes возвращает громкость каждого канала (0.0 - 1.0)
+func (am *AudioManager) GetChannelVolumes(deviceID string) ([]float32, error) {
+	var levels []float32
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		count, err := channelCount(volume, vtbl)
+		if err != nil {
+			return err
+		}
+
+		levels = make([]float32, count)
+		for i := range levels {
+			hr, _, _ := syscall.SyscallN(
+				vtbl.GetChannelVolumeLevelScalar,
+				uintptr(unsafe.Pointer(volume)),
+				uintptr(i),
+				uintptr(unsafe.Pointer(&levels[i])),
+			)
+			if hr != 0 {
+				return fmt.Errorf("failed to get channel %d volume: %x", i, hr)
+			}
+		}
+		return nil
+	})
+	return levels, err
+}
+
+// SetChannelVolumes устанавливает громкость каждого канала (0.0 - 1.0)
+func (am *AudioManager) SetChannelVolumes(deviceID string, levels []float32) error {
+	return am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		count, err := channelCount(volume, vtbl)
+		if err != nil {
+			return err
+		}
+		if len(levels) != int(count) {
+			return fmt.Errorf("device has %d channels, got %d levels", count, len(levels))
+		}
+
+		for i, level := range levels {
+			level = clampVolume(level)
+			hr, _, _ := syscall.SyscallN(
+				vtbl.SetChannelVolumeLevelScalar,
+				uintptr(unsafe.Pointer(volume)),
+				uintptr(i),
+				uintptr(*(*uint32)(unsafe.Pointer(&level))),
+				uintptr(unsafe.Pointer(EventContext)),
+			)
+			if hr != 0 {
+				return fmt.Errorf("failed to set channel %d volume: %x", i, hr)
+			}
+		}
+		return nil
+	})
+}
+
+// channelCount возвращает число каналов устройства
+func channelCount(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) (uint32, error) {
+	var count uint32
 	hr, _, _ := syscall.SyscallN(
-		vtbl.GetMasterVolumeLevelScalar,
+		vtbl.GetChannelCount,
 		uintptr(unsafe.Pointer(volume)),
-		uintptr(unsafe.Pointer(&level)),
+		uintptr(unsafe.Pointer(&count)),
 	)
 	if hr != 0 {
-		return 0, fmt.Errorf("failed to get volume level: %x", hr)
+		return 0, fmt.Errorf("failed to get channel count: %x", hr)
 	}
+	return count, nil
+}
 
-	return level, nil
+// GetVolumeRange возвращает диапазон громкости устройства в децибелах
+func (am *AudioManager) GetVolumeRange(deviceID string) (VolumeRange, error) {
+	var r VolumeRange
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetVolumeRange,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&r.MinDB)),
+			uintptr(unsafe.Pointer(&r.MaxDB)),
+			uintptr(unsafe.Pointer(&r.StepDB)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get volume range: %x", hr)
+		}
+		return nil
+	})
+	return r, err
 }
 
-// SetDeviceVolume устанавливает громкость устройства (0.0 - 1.0)
-func (am *AudioManager) SetDeviceVolume(deviceID string, level float32) error {
-	device, err := am.getDeviceByID(deviceID)
-	if err != nil {
-		return err
-	}
-	defer device.Release()
+// GetDeviceVolumeDB возвращает громкость устройства в децибелах
+func (am *AudioManager) GetDeviceVolumeDB(deviceID string) (float32, error) {
+	var db float32
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetMasterVolumeLevel,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&db)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get volume level in dB: %x", hr)
+		}
+		return nil
+	})
+	return db, err
+}
 
-	volume, err := am.getEndpointVolume(device)
+// SetDeviceVolumeDB устанавливает громкость устройства в децибелах.
+// Значение вне диапазона устройства приводится к ближайшей границе.
+func (am *AudioManager) SetDeviceVolumeDB(deviceID string, db float32) error {
+	r, err := am.GetVolumeRange(deviceID)
 	if err != nil {
 		return err
 	}
-	defer volume.Release()
+	db = r.ClampDB(db)
 
-	vtbl := (*IAudioEndpointVolumeVtbl)(unsafe.Pointer(volume.RawVTable))
+	return am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.SetMasterVolumeLevel,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(*(*uint32)(unsafe.Pointer(&db))),
+			uintptr(unsafe.Pointer(EventContext)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to set volume level in dB: %x", hr)
+		}
+		return nil
+	})
+}
 
-	// Ограничиваем значение
-	if level < 0 {
-		level = 0
-	}
-	if level > 1 {
-		level = 1
-	}
+// GetDeviceMute возвращает, выключен ли звук устройства
+func (am *AudioManager) GetDeviceMute(deviceID string) (bool, error) {
+	var muted int32
+	err := am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.GetMute,
+			uintptr(unsafe.Pointer(volume)),
+			uintptr(unsafe.Pointer(&muted)),
+		)
+		if hr != 0 {
+			return fmt.Errorf("failed to get mute state: %x", hr)
+		}
+		return nil
+	})
+	return muted != 0, err
+}
 
-	hr, _, _ := syscall.SyscallN(
-		vtbl.SetMasterVolumeLevelScalar,
-		uintptr(unsafe.Pointer(volume)),
-		uintptr(*(*uint32)(unsafe.Pointer(&level))),
-		0, // pguidEventContext
-	)
-	if hr != 0 {
-		return fmt.Errorf("failed to set volume level: %x", hr)
+// SetDeviceMute выключает или включает звук устройства
+func (am *AudioManager) SetDeviceMute(deviceID string, muted bool) error {
+	var value uintptr
+	if muted {
+		value = 1
 	}
 
-	return nil
+	return am.withEndpointVolume(deviceID, func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error {
+		hr, _, _ := syscall.SyscallN(
+			vtbl.SetMute,
+			uintptr(unsafe.Pointer(volume)),
+			value,
+			uintptr(unsafe.Pointer(EventContext)),
+		)
+		// S_FALSE (1) означает, что состояние уже было таким
+		if hr != 0 && hr != 1 {
+			return fmt.Errorf("failed to set mute state: %x", hr)
+		}
+		return nil
+	})
 }
 
 // GetDefaultOutputVolume возвращает громкость устройства вывода по умолчанию
@@ -560,6 +733,24 @@ func (am *AudioManager) getDeviceByID(deviceID string) (*IMMDevice, error) {
 	return device, nil
 }
 
+// withEndpointVolume вызывает fn с IAudioEndpointVolume устройства
+// и освобождает интерфейсы после вызова
+func (am *AudioManager) withEndpointVolume(deviceID string, fn func(volume *IAudioEndpointVolume, vtbl *IAudioEndpointVolumeVtbl) error) error {
+	device, err := am.getDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	defer device.Release()
+
+	volume, err := am.getEndpointVolume(device)
+	if err != nil {
+		return err
+	}
+	defer volume.Release()
+
+	return fn(volume, (*IAudioEndpointVolumeVtbl)(unsafe.Pointer(volume.RawVTable)))
+}
+
 // getEndpointVolume получает интерфейс IAudioEndpointVolume для устройства
 func (am *AudioManager) getEndpointVolume(device *IMMDevice) (*IAudioEndpointVolume, error) {
 	vtbl := (*IMMDeviceVtbl)(unsafe.Pointer(device.RawVTable))
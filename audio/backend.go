@@ -0,0 +1,340 @@
+package audio
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+)
+
+// EDataFlow - направление потока данных
+type EDataFlow uint32
+
+const (
+	ERender  EDataFlow = 0 // Устройства вывода (колонки, наушники)
+	ECapture EDataFlow = 1 // Устройства ввода (микрофоны)
+	EAll     EDataFlow = 2
+)
+
+// ERole - роль устройства
+type ERole uint32
+
+const (
+	EConsole       ERole = 0
+	EMultimedia    ERole = 1
+	ECommunication ERole = 2
+)
+
+// Roles - все роли в порядке, в котором Windows их перечисляет
+var Roles = []ERole{EConsole, EMultimedia, ECommunication}
+
+// String возвращает имя роли, используемое в настройках и во фронтенде
+func (r ERole) String() string {
+	switch r {
+	case EConsole:
+		return "console"
+	case EMultimedia:
+		return "multimedia"
+	case ECommunication:
+		return "communications"
+	}
+	return "unknown"
+}
+
+// ParseRole разбирает имя роли, возвращённое ERole.String
+func ParseRole(name string) (ERole, bool) {
+	for _, role := range Roles {
+		if role.String() == name {
+			return role, true
+		}
+	}
+	return 0, false
+}
+
+// DEVICE_STATE константы
+const (
+	DEVICE_STATE_ACTIVE     = 0x00000001
+	DEVICE_STATE_DISABLED   = 0x00000002
+	DEVICE_STATE_NOTPRESENT = 0x00000004
+	DEVICE_STATE_UNPLUGGED  = 0x00000008
+	DEVICE_STATEMASK_ALL    = 0x0000000F
+)
+
+// StateName возвращает имя состояния DEVICE_STATE_* для фронтенда
+func StateName(state uint32) string {
+	switch state {
+	case DEVICE_STATE_ACTIVE:
+		return "active"
+	case DEVICE_STATE_DISABLED:
+		return "disabled"
+	case DEVICE_STATE_NOTPRESENT:
+		return "notpresent"
+	case DEVICE_STATE_UNPLUGGED:
+		return "unplugged"
+	}
+	return "unknown"
+}
+
+// EndpointFormFactor - форм-фактор конечной точки (PKEY_AudioEndpoint_FormFactor)
+type EndpointFormFactor uint32
+
+const (
+	RemoteNetworkDevice       EndpointFormFactor = 0
+	Speakers                  EndpointFormFactor = 1
+	LineLevel                 EndpointFormFactor = 2
+	Headphones                EndpointFormFactor = 3
+	Microphone                EndpointFormFactor = 4
+	Headset                   EndpointFormFactor = 5
+	Handset                   EndpointFormFactor = 6
+	UnknownDigitalPassthrough EndpointFormFactor = 7
+	SPDIF                     EndpointFormFactor = 8
+	DigitalAudioDisplayDevice EndpointFormFactor = 9 // HDMI, DisplayPort
+	UnknownFormFactor         EndpointFormFactor = 10
+)
+
+// String возвращает имя форм-фактора для фронтенда
+func (f EndpointFormFactor) String() string {
+	switch f {
+	case RemoteNetworkDevice:
+		return "network"
+	case Speakers:
+		return "speakers"
+	case LineLevel:
+		return "line"
+	case Headphones:
+		return "headphones"
+	case Microphone:
+		return "microphone"
+	case Headset:
+		return "headset"
+	case Handset:
+		return "handset"
+	case UnknownDigitalPassthrough:
+		return "digital"
+	case SPDIF:
+		return "spdif"
+	case DigitalAudioDisplayDevice:
+		return "hdmi"
+	}
+	return "unknown"
+}
+
+// DeviceProperties - сведения об устройстве из хранилища свойств
+type DeviceProperties struct {
+	FormFactor    EndpointFormFactor
+	Description   string // PKEY_Device_DeviceDesc, например "Динамики"
+	InterfaceName string // PKEY_DeviceInterface_FriendlyName, например "Realtek(R) Audio"
+	ContainerID   string // PKEY_Device_ContainerId, общий для конечных точек одного устройства
+	Enumerator    string // PKEY_Device_EnumeratorName: USB, BTHENUM, HDAUDIO...
+	JackSubType   string // PKEY_AudioEndpoint_JackSubType, KSNODETYPE GUID разъёма
+	IconPath      string // PKEY_DeviceClass_IconPath
+}
+
+// AudioDevice представляет аудиоустройство
+type AudioDevice struct {
+	ID           string
+	Name         string
+	IsDefault    bool    // устройство по умолчанию для EMultimedia
+	DefaultRoles []ERole // роли, для которых устройство выбрано по умолчанию
+	DataFlow     EDataFlow
+	FriendlyName string
+	State        uint32 // DEVICE_STATE_*
+
+	DeviceProperties
+}
+
+// DeviceEventType - тип события об изменении устройств
+type DeviceEventType int
+
+const (
+	DeviceStateChanged DeviceEventType = iota
+	DeviceAdded
+	DeviceRemoved
+	DefaultDeviceChanged
+)
+
+func (t DeviceEventType) String() string {
+	switch t {
+	case DeviceStateChanged:
+		return "state changed"
+	case DeviceAdded:
+		return "added"
+	case DeviceRemoved:
+		return "removed"
+	case DefaultDeviceChanged:
+		return "default changed"
+	}
+	return "unknown"
+}
+
+// DeviceEvent - уведомление IMMNotificationClient
+type DeviceEvent struct {
+	Type     DeviceEventType
+	DeviceID string
+	DataFlow EDataFlow // EAll, если направление не удалось определить
+	Role     ERole     // только для DefaultDeviceChanged
+	State    uint32    // только для DeviceStateChanged
+}
+
+// VolumeEvent - уведомление IAudioEndpointVolumeCallback об изменении громкости
+type VolumeEvent struct {
+	DeviceID string
+	DataFlow EDataFlow
+	Level    float32   // общая громкость (0.0 - 1.0)
+	Muted    bool      // состояние выключения звука
+	Channels []float32 // громкость по каналам (0.0 - 1.0)
+
+	EventContext ole.GUID // pguidEventContext автора изменения
+	Self         bool     // изменение сделано AutoSound (EventContext совпал)
+}
+
+// EventContext - GUID процесса, которым AutoSound помечает свои изменения
+// громкости и mute. По нему уведомления отличают наши записи от чужих.
+var EventContext = newEventContext()
+
+// newEventContext генерирует случайный GUID версии 4
+func newEventContext() *ole.GUID {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("audio: failed to generate event context: " + err.Error())
+	}
+	b[6] = b[6]&0x0F | 0x40 // версия 4
+	b[8] = b[8]&0x3F | 0x80 // вариант RFC 4122
+
+	return &ole.GUID{
+		Data1: uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]),
+		Data2: uint16(b[4])<<8 | uint16(b[5]),
+		Data3: uint16(b[6])<<8 | uint16(b[7]),
+		Data4: [8]byte{b[8], b[9], b[10], b[11], b[12], b[13], b[14], b[15]},
+	}
+}
+
+// IsSelfContext проверяет, что изменение помечено контекстом AutoSound
+func IsSelfContext(guid *ole.GUID) bool {
+	return guid != nil && ole.IsEqualGUID(guid, EventContext)
+}
+
+// Размеры буферов каналов событий. При переполнении события
+// отбрасываются: получатель всё равно перечитывает текущее состояние.
+const (
+	deviceEventBuffer = 64
+	volumeEventBuffer = 64
+)
+
+// DataFlowFromID определяет направление по ID конечной точки
+// ("{0.0.0.00000000}.{...}" - вывод, "{0.0.1.00000000}.{...}" - ввод)
+func DataFlowFromID(deviceID string) EDataFlow {
+	switch {
+	case strings.HasPrefix(deviceID, "{0.0.0."):
+		return ERender
+	case strings.HasPrefix(deviceID, "{0.0.1."):
+		return ECapture
+	}
+	return EAll
+}
+
+// defaultRolesOf возвращает роли, для которых deviceID выбран по умолчанию
+func defaultRolesOf(deviceID string, defaultIDs map[ERole]string) []ERole {
+	var roles []ERole
+	for _, role := range Roles {
+		if defaultIDs[role] == deviceID {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// ErrUnsupported возвращается, если на платформе нет аудио бэкенда
+var ErrUnsupported = errors.New("audio backend is not supported on this platform")
+
+// VolumeStepInfo - положение громкости в аппаратных шагах
+// (IAudioEndpointVolume::GetVolumeStepInfo): Step от 0 до Count-1
+type VolumeStepInfo struct {
+	Step  uint32
+	Count uint32
+}
+
+// Backend описывает операции над аудиоустройствами, которые нужны приложению.
+// AudioManager реализует его через COM, FakeBackend - в памяти для тестов.
+type Backend interface {
+	// Close освобождает ресурсы и отписывается от уведомлений
+	Close()
+
+	// WatchDevices подписывается на уведомления об изменении устройств.
+	// Канал не закрывается; подписка снимается в Close.
+	WatchDevices() (<-chan DeviceEvent, error)
+
+	// VolumeEvents возвращает общий канал уведомлений о громкости
+	// всех устройств, на которые есть подписка WatchVolume
+	VolumeEvents() <-chan VolumeEvent
+	// WatchVolume подписывается на изменения громкости устройства
+	WatchVolume(deviceID string) error
+	// UnwatchVolume снимает подписку на громкость устройства
+	UnwatchVolume(deviceID string)
+
+	// GetOutputDevices возвращает список активных устройств вывода
+	GetOutputDevices() ([]AudioDevice, error)
+	// GetInputDevices возвращает список активных устройств ввода
+	GetInputDevices() ([]AudioDevice, error)
+	// GetDevices возвращает устройства, состояние которых входит в stateMask
+	GetDevices(dataFlow EDataFlow, stateMask uint32) ([]AudioDevice, error)
+
+	// SetDefaultDevice устанавливает устройство по умолчанию для всех ролей
+	SetDefaultDevice(deviceID string) error
+	// SetDefaultDeviceForRole устанавливает устройство по умолчанию для одной роли
+	SetDefaultDeviceForRole(deviceID string, role ERole) error
+	// GetDefaultDeviceID возвращает ID устройства по умолчанию для роли
+	GetDefaultDeviceID(dataFlow EDataFlow, role ERole) string
+	// GetCurrentDefaultOutputID возвращает ID текущего устройства вывода по умолчанию
+	GetCurrentDefaultOutputID() string
+	// GetCurrentDefaultInputID возвращает ID текущего устройства ввода по умолчанию
+	GetCurrentDefaultInputID() string
+
+	// GetDeviceVolume возвращает громкость устройства (0.0 - 1.0)
+	GetDeviceVolume(deviceID string) (float32, error)
+	// SetDeviceVolume устанавливает громкость устройства (0.0 - 1.0)
+	SetDeviceVolume(deviceID string, level float32) error
+
+	// AdjustDeviceVolume меняет громкость на delta (-1.0 - 1.0) с ограничением
+	// диапазоном 0.0 - 1.0 и возвращает новую громкость
+	AdjustDeviceVolume(deviceID string, delta float32) (float32, error)
+	// GetVolumeStepInfo возвращает текущий шаг громкости и число шагов
+	GetVolumeStepInfo(deviceID string) (VolumeStepInfo, error)
+	// VolumeStepUp увеличивает громкость на один аппаратный шаг
+	VolumeStepUp(deviceID string) error
+	// VolumeStepDown уменьшает громкость на один аппаратный шаг
+	VolumeStepDown(deviceID string) error
+
+	// GetChannelVolumes возвращает громкость каждого канала (0.0 - 1.0)
+	GetChannelVolumes(deviceID string) ([]float32, error)
+	// SetChannelVolumes устанавливает громкость каждого канала; длина
+	// среза должна совпадать с числом каналов устройства
+	SetChannelVolumes(deviceID string, levels []float32) error
+
+	// GetVolumeRange возвращает диапазон громкости устройства в децибелах
+	GetVolumeRange(deviceID string) (VolumeRange, error)
+	// GetDeviceVolumeDB возвращает громкость устройства в децибелах
+	GetDeviceVolumeDB(deviceID string) (float32, error)
+	// SetDeviceVolumeDB устанавливает громкость устройства в децибелах
+	SetDeviceVolumeDB(deviceID string, db float32) error
+
+	// GetDeviceMute возвращает, выключен ли звук устройства
+	GetDeviceMute(deviceID string) (bool, error)
+	// SetDeviceMute выключает или включает звук устройства
+	SetDeviceMute(deviceID string, muted bool) error
+
+	// GetDefaultOutputVolume возвращает громкость устройства вывода по умолчанию
+	GetDefaultOutputVolume() (float32, error)
+	// GetDefaultInputVolume возвращает громкость устройства ввода по умолчанию
+	GetDefaultInputVolume() (float32, error)
+	// SetDefaultOutputVolume устанавливает громкость устройства вывода по умолчанию
+	SetDefaultOutputVolume(level float32) error
+	// SetDefaultInputVolume устанавливает громкость устройства ввода по умолчанию
+	SetDefaultInputVolume(level float32) error
+
+	// GetDeviceProperty читает свойство из хранилища свойств устройства
+	GetDeviceProperty(deviceID string, key PROPERTYKEY) (any, error)
+	// SetDeviceProperty записывает свойство в хранилище свойств устройства
+	SetDeviceProperty(deviceID string, key PROPERTYKEY, value any) error
+}
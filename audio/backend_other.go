@@ -0,0 +1,8 @@
+//go:build !windows
+
+package audio
+
+// NewBackend на платформах без Core Audio возвращает ErrUnsupported
+func NewBackend() (Backend, error) {
+	return nil, ErrUnsupported
+}
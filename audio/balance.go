@@ -0,0 +1,39 @@
+package audio
+
+// Balance вычисляет баланс по громкостям каналов: -1 - только левый,
+// 0 - по центру, 1 - только правый. Учитываются первые два канала (L/R).
+func Balance(channels []float32) float32 {
+	if len(channels) < 2 {
+		return 0
+	}
+	left, right := channels[0], channels[1]
+	switch {
+	case left == right:
+		return 0
+	case left > right:
+		return right/left - 1
+	default:
+		return 1 - left/right
+	}
+}
+
+// ChannelsForBalance раскладывает общую громкость по count каналам так,
+// чтобы получился заданный баланс. Громче остаётся канал на стороне
+// баланса, остальные каналы (центр, тыл) получают общую громкость.
+func ChannelsForBalance(master, balance float32, count int) []float32 {
+	channels := make([]float32, count)
+	for i := range channels {
+		channels[i] = master
+	}
+	if count < 2 {
+		return channels
+	}
+
+	balance = max(-1, min(1, balance))
+	if balance > 0 {
+		channels[0] = master * (1 - balance)
+	} else {
+		channels[1] = master * (1 + balance)
+	}
+	return channels
+}
@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// CurveKind - вид кривой, связывающей положение слайдера с громкостью
+type CurveKind int
+
+const (
+	CurveLinear CurveKind = iota // слайдер равен скаляру Windows
+	CurveLog                     // слайдер равномерен по децибелам
+	CurveCustom                  // ломаная по контрольным точкам
+)
+
+// String возвращает имя кривой, используемое в настройках и во фронтенде
+func (k CurveKind) String() string {
+	switch k {
+	case CurveLinear:
+		return "linear"
+	case CurveLog:
+		return "log"
+	case CurveCustom:
+		return "custom"
+	}
+	return "unknown"
+}
+
+// ParseCurveKind разбирает имя кривой, возвращённое CurveKind.String
+func ParseCurveKind(name string) (CurveKind, bool) {
+	for _, kind := range []CurveKind{CurveLinear, CurveLog, CurveCustom} {
+		if kind.String() == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// logCurveRangeDB - диапазон логарифмической кривой: слайдер от 0 до 1
+// проходит громкость от -40 dB до 0 dB относительно максимума
+const logCurveRangeDB = 40
+
+// VolumeCurve отображает положение слайдера (0.0 - 1.0) на скаляр громкости
+// Windows и обратно. Точки [положение, громкость] задают кривую CurveCustom:
+// положения возрастают, громкость не убывает, между точками - отрезки.
+type VolumeCurve struct {
+	Kind   CurveKind
+	Points [][2]float32
+}
+
+// NewVolumeCurve разбирает и проверяет кривую из настроек
+func NewVolumeCurve(kind string, points [][2]float32) (VolumeCurve, error) {
+	if kind == "" {
+		return VolumeCurve{Kind: CurveLinear}, nil
+	}
+	k, ok := ParseCurveKind(kind)
+	if !ok {
+		return VolumeCurve{}, fmt.Errorf("unknown volume curve %q", kind)
+	}
+	if k != CurveCustom {
+		return VolumeCurve{Kind: k}, nil
+	}
+
+	if len(points) == 0 {
+		return VolumeCurve{}, fmt.Errorf("custom volume curve has no points")
+	}
+	for i, p := range points {
+		if p[0] < 0 || p[0] > 1 || p[1] < 0 || p[1] > 1 {
+			return VolumeCurve{}, fmt.Errorf("curve point %d (%.2f, %.2f) is out of range 0..1", i, p[0], p[1])
+		}
+		if i > 0 && p[0] <= points[i-1][0] {
+			return VolumeCurve{}, fmt.Errorf("curve point %d: slider positions must increase", i)
+		}
+		if i > 0 && p[1] < points[i-1][1] {
+			return VolumeCurve{}, fmt.Errorf("curve point %d: volume must not decrease", i)
+		}
+	}
+
+	// Концы кривой закрепляем в 0 и 1, если точки их не задают
+	full := make([][2]float32, 0, len(points)+2)
+	if points[0][0] > 0 {
+		full = append(full, [2]float32{0, 0})
+	}
+	full = append(full, points...)
+	if points[len(points)-1][0] < 1 {
+		full = append(full, [2]float32{1, 1})
+	}
+	return VolumeCurve{Kind: CurveCustom, Points: full}, nil
+}
+
+// ToDevice переводит положение слайдера в громкость устройства
+func (c VolumeCurve) ToDevice(position float32) float32 {
+	position = clampVolume(position)
+	switch c.Kind {
+	case CurveLog:
+		if position <= 0 {
+			return 0
+		}
+		return clampVolume(float32(math.Pow(10, float64(position-1)*logCurveRangeDB/20)))
+	case CurveCustom:
+		return piecewise(c.Points, position, 0, 1)
+	}
+	return position
+}
+
+// FromDevice переводит громкость устройства в положение слайдера
+func (c VolumeCurve) FromDevice(level float32) float32 {
+	level = clampVolume(level)
+	switch c.Kind {
+	case CurveLog:
+		if level <= 0 {
+			return 0
+		}
+		return clampVolume(1 + float32(20*math.Log10(float64(level)))/logCurveRangeDB)
+	case CurveCustom:
+		return piecewise(c.Points, level, 1, 0)
+	}
+	return level
+}
+
+// piecewise интерполирует ломаную: x берётся из координаты in точек,
+// результат - из координаты out. На горизонтальном участке обратного
+// отображения возвращается его начало.
+func piecewise(points [][2]float32, x float32, in, out int) float32 {
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if x > b[in] {
+			continue
+		}
+		if b[in] == a[in] {
+			return a[out]
+		}
+		t := (x - a[in]) / (b[in] - a[in])
+		return clampVolume(a[out] + (b[out]-a[out])*t)
+	}
+	return points[len(points)-1][out]
+}
@@ -0,0 +1,98 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVolumeCurveRoundTrip(t *testing.T) {
+	mustCurve := func(kind string, points [][2]float32) VolumeCurve {
+		t.Helper()
+		curve, err := NewVolumeCurve(kind, points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return curve
+	}
+	linear := mustCurve("", nil)
+	logCurve := mustCurve("log", nil)
+	custom := mustCurve("custom", [][2]float32{{0.5, 0.1}, {0.75, 0.5}})
+	// Горизонтальный участок: положения 0.2-0.4 дают одну громкость
+	flat := mustCurve("custom", [][2]float32{{0.2, 0.3}, {0.4, 0.3}})
+
+	tests := []struct {
+		name  string
+		curve VolumeCurve
+		level float32
+		want  float32
+	}{
+		{"linear zero", linear, 0, 0},
+		{"linear mid", linear, 0.37, 0.37},
+		{"linear full", linear, 1, 1},
+		{"linear above range", linear, 1.5, 1},
+		{"log zero", logCurve, 0, 0},
+		{"log -20 dB", logCurve, 0.1, 0.1},
+		{"log mid", logCurve, 0.5, 0.5},
+		{"log full", logCurve, 1, 1},
+		// Тише нижней границы диапазона кривая даёт тишину
+		{"log below range", logCurve, 0.005, 0},
+		{"custom zero", custom, 0, 0},
+		{"custom first segment", custom, 0.05, 0.05},
+		{"custom point", custom, 0.1, 0.1},
+		{"custom middle segment", custom, 0.3, 0.3},
+		{"custom last segment", custom, 0.8, 0.8},
+		{"custom full", custom, 1, 1},
+		{"flat below", flat, 0.15, 0.15},
+		{"flat level", flat, 0.3, 0.3},
+		{"flat above", flat, 0.65, 0.65},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := tt.curve.FromDevice(tt.level)
+			if position < 0 || position > 1 {
+				t.Fatalf("FromDevice(%v) = %v, out of range 0..1", tt.level, position)
+			}
+			if got := tt.curve.ToDevice(position); math.Abs(float64(got-tt.want)) > 1e-5 {
+				t.Errorf("ToDevice(FromDevice(%v)) = %v (position %v), want %v", tt.level, got, position, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeCurvePositions(t *testing.T) {
+	logCurve, _ := NewVolumeCurve("log", nil)
+	tests := []struct {
+		position float32
+		wantDB   float64
+	}{
+		{1, 0},
+		{0.5, -logCurveRangeDB / 2},
+		{0.25, -logCurveRangeDB * 3 / 4},
+	}
+	for _, tt := range tests {
+		level := logCurve.ToDevice(tt.position)
+		if db := 20 * math.Log10(float64(level)); math.Abs(db-tt.wantDB) > 1e-3 {
+			t.Errorf("ToDevice(%v) = %.2f dB, want %.2f dB", tt.position, db, tt.wantDB)
+		}
+	}
+}
+
+func TestNewVolumeCurveErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		kind   string
+		points [][2]float32
+	}{
+		{"unknown kind", "cubic", nil},
+		{"custom without points", "custom", nil},
+		{"point out of range", "custom", [][2]float32{{0.5, 1.5}}},
+		{"positions not increasing", "custom", [][2]float32{{0.5, 0.2}, {0.5, 0.4}}},
+		{"volume decreasing", "custom", [][2]float32{{0.2, 0.4}, {0.5, 0.3}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewVolumeCurve(tt.kind, tt.points); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
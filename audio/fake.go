@@ -0,0 +1,631 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/go-ole/go-ole"
+)
+
+// FakeBackend - реализация Backend в памяти, не требующая Windows.
+// Используется в тестах: устройства добавляются через AddDevice, внешние
+// изменения имитируются вызовами SetDefaultDevice и SetExternalVolume.
+type FakeBackend struct {
+	mu       sync.Mutex
+	devices  []*fakeDevice
+	defaults map[fakeRoleKey]string
+	events   chan DeviceEvent
+
+	volumeEvents  chan VolumeEvent
+	volumeWatches map[string]bool
+
+	// Свойства хранятся закодированными, как в настоящем хранилище
+	memory *heapMemory
+}
+
+type fakeRoleKey struct {
+	dataFlow EDataFlow
+	role     ERole
+}
+
+type fakeDevice struct {
+	id       string
+	name     string
+	dataFlow EDataFlow
+	volume   float32
+	channels []float32
+	volRange VolumeRange
+	muted    bool
+	state    uint32
+	props    DeviceProperties
+	store    map[PROPERTYKEY][]byte
+}
+
+var _ Backend = (*FakeBackend)(nil)
+
+// NewFakeBackend создает пустой бэкенд в памяти
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		defaults: make(map[fakeRoleKey]string),
+		events:   make(chan DeviceEvent, deviceEventBuffer),
+
+		volumeEvents:  make(chan VolumeEvent, volumeEventBuffer),
+		volumeWatches: make(map[string]bool),
+		memory:        newHeapMemory(),
+	}
+}
+
+// AddDevice добавляет устройство. Первое устройство направления становится
+// устройством по умолчанию.
+func (f *FakeBackend) AddDevice(id, name string, dataFlow EDataFlow, volume float32) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.devices = append(f.devices, &fakeDevice{
+		id:       id,
+		name:     name,
+		dataFlow: dataFlow,
+		volume:   clampVolume(volume),
+		channels: []float32{clampVolume(volume), clampVolume(volume)},
+		volRange: defaultVolumeRange,
+		state:    DEVICE_STATE_ACTIVE,
+		props:    DeviceProperties{FormFactor: UnknownFormFactor},
+		store:    make(map[PROPERTYKEY][]byte),
+	})
+	f.emit(DeviceEvent{Type: DeviceAdded, DeviceID: id, DataFlow: dataFlow})
+	for _, role := range Roles {
+		if f.defaults[fakeRoleKey{dataFlow, role}] == "" {
+			f.setDefault(dataFlow, role, id)
+		}
+	}
+}
+
+// RemoveDevice удаляет устройство, как будто его отключили
+func (f *FakeBackend) RemoveDevice(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, dev := range f.devices {
+		if dev.id != id {
+			continue
+		}
+		f.devices = append(f.devices[:i], f.devices[i+1:]...)
+		for _, raw := range dev.store {
+			clearPropVariant(raw, f.memory)
+		}
+		delete(f.volumeWatches, id)
+		f.emit(DeviceEvent{Type: DeviceRemoved, DeviceID: id, DataFlow: dev.dataFlow})
+		f.reassignDefaults(dev)
+		return
+	}
+}
+
+// SetDeviceProperties задаёт сведения об устройстве из хранилища свойств
+func (f *FakeBackend) SetDeviceProperties(id string, props DeviceProperties) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(id)
+	if err != nil {
+		return err
+	}
+	dev.props = props
+	return nil
+}
+
+// SetDeviceState меняет состояние устройства (DEVICE_STATE_*), например
+// имитируя отключение кабеля без удаления конечной точки
+func (f *FakeBackend) SetDeviceState(id string, state uint32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(id)
+	if err != nil {
+		return err
+	}
+	dev.state = state
+	f.emit(DeviceEvent{Type: DeviceStateChanged, DeviceID: id, DataFlow: dev.dataFlow, State: state})
+	if state != DEVICE_STATE_ACTIVE {
+		f.reassignDefaults(dev)
+	}
+	return nil
+}
+
+// reassignDefaults передаёт роли, которые занимало неактивное устройство,
+// первому активному устройству того же направления. Вызывается под f.mu.
+func (f *FakeBackend) reassignDefaults(dev *fakeDevice) {
+	next := ""
+	for _, other := range f.devices {
+		if other != dev && other.dataFlow == dev.dataFlow && other.state == DEVICE_STATE_ACTIVE {
+			next = other.id
+			break
+		}
+	}
+	for _, role := range Roles {
+		if f.defaults[fakeRoleKey{dev.dataFlow, role}] == dev.id {
+			f.setDefault(dev.dataFlow, role, next)
+		}
+	}
+}
+
+// Close ничего не делает: бэкенд в памяти не держит ресурсов
+func (f *FakeBackend) Close() {}
+
+// WatchDevices возвращает канал, в который попадают все изменения устройств
+func (f *FakeBackend) WatchDevices() (<-chan DeviceEvent, error) {
+	return f.events, nil
+}
+
+// VolumeEvents возвращает общий канал уведомлений о громкости
+func (f *FakeBackend) VolumeEvents() <-chan VolumeEvent {
+	return f.volumeEvents
+}
+
+// WatchVolume подписывается на изменения громкости устройства
+func (f *FakeBackend) WatchVolume(deviceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, err := f.find(deviceID); err != nil {
+		return err
+	}
+	f.volumeWatches[deviceID] = true
+	return nil
+}
+
+// UnwatchVolume снимает подписку на громкость устройства
+func (f *FakeBackend) UnwatchVolume(deviceID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.volumeWatches, deviceID)
+}
+
+// GetOutputDevices возвращает список активных устройств вывода
+func (f *FakeBackend) GetOutputDevices() ([]AudioDevice, error) {
+	return f.GetDevices(ERender, DEVICE_STATE_ACTIVE)
+}
+
+// GetInputDevices возвращает список активных устройств ввода
+func (f *FakeBackend) GetInputDevices() ([]AudioDevice, error) {
+	return f.GetDevices(ECapture, DEVICE_STATE_ACTIVE)
+}
+
+// GetDevices возвращает устройства, состояние которых входит в stateMask
+func (f *FakeBackend) GetDevices(dataFlow EDataFlow, stateMask uint32) ([]AudioDevice, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	defaultIDs := make(map[ERole]string, len(Roles))
+	for _, role := range Roles {
+		defaultIDs[role] = f.defaults[fakeRoleKey{dataFlow, role}]
+	}
+
+	devices := make([]AudioDevice, 0, len(f.devices))
+	for _, dev := range f.devices {
+		if dev.dataFlow != dataFlow || dev.state&stateMask == 0 {
+			continue
+		}
+		devices = append(devices, AudioDevice{
+			ID:           dev.id,
+			Name:         dev.name,
+			FriendlyName: dev.name,
+			IsDefault:    dev.id == defaultIDs[EMultimedia],
+			DefaultRoles: defaultRolesOf(dev.id, defaultIDs),
+			DataFlow:     dataFlow,
+			State:        dev.state,
+
+			DeviceProperties: dev.props,
+		})
+	}
+	return devices, nil
+}
+
+// SetDefaultDevice устанавливает устройство по умолчанию для всех ролей
+func (f *FakeBackend) SetDefaultDevice(deviceID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.findActive(deviceID)
+	if err != nil {
+		return err
+	}
+	for _, role := range Roles {
+		f.setDefault(dev.dataFlow, role, deviceID)
+	}
+	return nil
+}
+
+// SetDefaultDeviceForRole устанавливает устройство по умолчанию для одной роли
+func (f *FakeBackend) SetDefaultDeviceForRole(deviceID string, role ERole) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.findActive(deviceID)
+	if err != nil {
+		return err
+	}
+	f.setDefault(dev.dataFlow, role, deviceID)
+	return nil
+}
+
+// GetDefaultDeviceID возвращает ID устройства по умолчанию для роли
+func (f *FakeBackend) GetDefaultDeviceID(dataFlow EDataFlow, role ERole) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.defaults[fakeRoleKey{dataFlow, role}]
+}
+
+// GetCurrentDefaultOutputID возвращает ID текущего устройства вывода по умолчанию
+func (f *FakeBackend) GetCurrentDefaultOutputID() string {
+	return f.GetDefaultDeviceID(ERender, EMultimedia)
+}
+
+// GetCurrentDefaultInputID возвращает ID текущего устройства ввода по умолчанию
+func (f *FakeBackend) GetCurrentDefaultInputID() string {
+	return f.GetDefaultDeviceID(ECapture, EMultimedia)
+}
+
+// GetDeviceVolume возвращает громкость устройства (0.0 - 1.0)
+func (f *FakeBackend) GetDeviceVolume(deviceID string) (float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return dev.volume, nil
+}
+
+// SetDeviceVolume устанавливает громкость устройства (0.0 - 1.0)
+func (f *FakeBackend) SetDeviceVolume(deviceID string, level float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.setVolume(level)
+	f.emitVolume(dev, EventContext)
+	return nil
+}
+
+// SetExternalVolume меняет громкость так, как это сделало бы другое приложение
+func (f *FakeBackend) SetExternalVolume(deviceID string, level float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.setVolume(level)
+	f.emitVolume(dev, &ole.GUID{})
+	return nil
+}
+
+// SetVolumeRange задаёт диапазон громкости устройства в децибелах
+func (f *FakeBackend) SetVolumeRange(deviceID string, r VolumeRange) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.volRange = r
+	return nil
+}
+
+// fakeVolumeSteps - число аппаратных шагов громкости у фейковых устройств
+const fakeVolumeSteps = 50
+
+// AdjustDeviceVolume меняет громкость на delta и возвращает новую громкость
+func (f *FakeBackend) AdjustDeviceVolume(deviceID string, delta float32) (float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	dev.setVolume(dev.volume + delta)
+	f.emitVolume(dev, EventContext)
+	return dev.volume, nil
+}
+
+// GetVolumeStepInfo возвращает текущий шаг громкости и число шагов
+func (f *FakeBackend) GetVolumeStepInfo(deviceID string) (VolumeStepInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return VolumeStepInfo{}, err
+	}
+	step := uint32(math.Round(float64(dev.volume) * (fakeVolumeSteps - 1)))
+	return VolumeStepInfo{Step: step, Count: fakeVolumeSteps}, nil
+}
+
+// VolumeStepUp увеличивает громкость на один шаг
+func (f *FakeBackend) VolumeStepUp(deviceID string) error {
+	_, err := f.AdjustDeviceVolume(deviceID, 1.0/(fakeVolumeSteps-1))
+	return err
+}
+
+// VolumeStepDown уменьшает громкость на один шаг
+func (f *FakeBackend) VolumeStepDown(deviceID string) error {
+	_, err := f.AdjustDeviceVolume(deviceID, -1.0/(fakeVolumeSteps-1))
+	return err
+}
+
+// GetChannelVolumes возвращает громкость каждого канала (0.0 - 1.0)
+func (f *FakeBackend) GetChannelVolumes(deviceID string) ([]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(dev.channels), nil
+}
+
+// SetChannelVolumes устанавливает громкость каждого канала. Общая громкость,
+// как в Windows, равна громкости самого громкого канала.
+func (f *FakeBackend) SetChannelVolumes(deviceID string, levels []float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	if len(levels) != len(dev.channels) {
+		return fmt.Errorf("device has %d channels, got %d levels", len(dev.channels), len(levels))
+	}
+	dev.volume = 0
+	for i, level := range levels {
+		dev.channels[i] = clampVolume(level)
+		dev.volume = max(dev.volume, dev.channels[i])
+	}
+	f.emitVolume(dev, EventContext)
+	return nil
+}
+
+// GetVolumeRange возвращает диапазон громкости устройства в децибелах
+func (f *FakeBackend) GetVolumeRange(deviceID string) (VolumeRange, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return VolumeRange{}, err
+	}
+	return dev.volRange, nil
+}
+
+// GetDeviceVolumeDB возвращает громкость устройства в децибелах.
+// Скаляр хранится как есть и переводится по VolumeRange.ScalarToDB.
+func (f *FakeBackend) GetDeviceVolumeDB(deviceID string) (float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return 0, err
+	}
+	return dev.volRange.ScalarToDB(dev.volume), nil
+}
+
+// SetDeviceVolumeDB устанавливает громкость устройства в децибелах
+func (f *FakeBackend) SetDeviceVolumeDB(deviceID string, db float32) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.setVolume(dev.volRange.DBToScalar(db))
+	f.emitVolume(dev, EventContext)
+	return nil
+}
+
+// GetDeviceMute возвращает, выключен ли звук устройства
+func (f *FakeBackend) GetDeviceMute(deviceID string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return false, err
+	}
+	return dev.muted, nil
+}
+
+// SetDeviceMute выключает или включает звук устройства
+func (f *FakeBackend) SetDeviceMute(deviceID string, muted bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.muted = muted
+	f.emitVolume(dev, EventContext)
+	return nil
+}
+
+// SetExternalMute меняет состояние звука так, как это сделало бы другое приложение
+func (f *FakeBackend) SetExternalMute(deviceID string, muted bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	dev.muted = muted
+	f.emitVolume(dev, &ole.GUID{})
+	return nil
+}
+
+// GetDefaultOutputVolume возвращает громкость устройства вывода по умолчанию
+func (f *FakeBackend) GetDefaultOutputVolume() (float32, error) {
+	deviceID := f.GetCurrentDefaultOutputID()
+	if deviceID == "" {
+		return 0, fmt.Errorf("no default output device")
+	}
+	return f.GetDeviceVolume(deviceID)
+}
+
+// GetDefaultInputVolume возвращает громкость устройства ввода по умолчанию
+func (f *FakeBackend) GetDefaultInputVolume() (float32, error) {
+	deviceID := f.GetCurrentDefaultInputID()
+	if deviceID == "" {
+		return 0, fmt.Errorf("no default input device")
+	}
+	return f.GetDeviceVolume(deviceID)
+}
+
+// SetDefaultOutputVolume устанавливает громкость устройства вывода по умолчанию
+func (f *FakeBackend) SetDefaultOutputVolume(level float32) error {
+	deviceID := f.GetCurrentDefaultOutputID()
+	if deviceID == "" {
+		return fmt.Errorf("no default output device")
+	}
+	return f.SetDeviceVolume(deviceID, level)
+}
+
+// SetDefaultInputVolume устанавливает громкость устройства ввода по умолчанию
+func (f *FakeBackend) SetDefaultInputVolume(level float32) error {
+	deviceID := f.GetCurrentDefaultInputID()
+	if deviceID == "" {
+		return fmt.Errorf("no default input device")
+	}
+	return f.SetDeviceVolume(deviceID, level)
+}
+
+// GetDeviceProperty декодирует сохранённое свойство устройства.
+// Отсутствующее свойство возвращается как nil, как VT_EMPTY в Windows.
+func (f *FakeBackend) GetDeviceProperty(deviceID string, key PROPERTYKEY) (any, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	raw, ok := dev.store[key]
+	if !ok {
+		return nil, nil
+	}
+	return decodePropVariant(raw, f.memory)
+}
+
+// SetDeviceProperty кодирует и сохраняет свойство устройства
+func (f *FakeBackend) SetDeviceProperty(deviceID string, key PROPERTYKEY, value any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return err
+	}
+	raw, err := encodePropVariant(value, f.memory)
+	if err != nil {
+		return err
+	}
+	if old, ok := dev.store[key]; ok {
+		clearPropVariant(old, f.memory)
+	}
+	dev.store[key] = raw
+	return nil
+}
+
+// setDefault меняет устройство по умолчанию для роли и уведомляет
+// подписчиков, как это делает Windows. Вызывается под f.mu.
+func (f *FakeBackend) setDefault(dataFlow EDataFlow, role ERole, deviceID string) {
+	f.defaults[fakeRoleKey{dataFlow, role}] = deviceID
+	f.emit(DeviceEvent{Type: DefaultDeviceChanged, DeviceID: deviceID, DataFlow: dataFlow, Role: role})
+}
+
+// emit отправляет событие без блокировки
+func (f *FakeBackend) emit(event DeviceEvent) {
+	select {
+	case f.events <- event:
+	default:
+	}
+}
+
+// emitVolume уведомляет подписчиков о громкости устройства. Вызывается под f.mu.
+func (f *FakeBackend) emitVolume(dev *fakeDevice, eventContext *ole.GUID) {
+	if !f.volumeWatches[dev.id] {
+		return
+	}
+	select {
+	case f.volumeEvents <- VolumeEvent{
+		DeviceID: dev.id,
+		DataFlow: dev.dataFlow,
+		Level:    dev.volume,
+		Muted:    dev.muted,
+		Channels: slices.Clone(dev.channels),
+
+		EventContext: *eventContext,
+		Self:         IsSelfContext(eventContext),
+	}:
+	default:
+	}
+}
+
+// setVolume меняет общую громкость, сохраняя соотношение каналов
+func (dev *fakeDevice) setVolume(level float32) {
+	level = clampVolume(level)
+	for i := range dev.channels {
+		if dev.volume > 0 {
+			dev.channels[i] = dev.channels[i] / dev.volume * level
+		} else {
+			dev.channels[i] = level
+		}
+	}
+	dev.volume = level
+}
+
+// find ищет устройство по ID, вызывается под f.mu
+func (f *FakeBackend) find(deviceID string) (*fakeDevice, error) {
+	for _, dev := range f.devices {
+		if dev.id == deviceID {
+			return dev, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get device: %s not found", deviceID)
+}
+
+// findActive ищет активное устройство: только оно может стать устройством
+// по умолчанию. Вызывается под f.mu.
+func (f *FakeBackend) findActive(deviceID string) (*fakeDevice, error) {
+	dev, err := f.find(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if dev.state != DEVICE_STATE_ACTIVE {
+		return nil, fmt.Errorf("device %s is %s", deviceID, StateName(dev.state))
+	}
+	return dev, nil
+}
+
+// clampVolume ограничивает уровень диапазоном 0.0 - 1.0
+func clampVolume(level float32) float32 {
+	if level < 0 {
+		return 0
+	}
+	if level > 1 {
+		return 1
+	}
+	return level
+}
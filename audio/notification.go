@@ -0,0 +1,168 @@
+//go:build windows
+
+package audio
+
+import (
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// IID для IMMNotificationClient
+var (
+	IID_IMMNotificationClient = ole.NewGUID("{7991EEC9-7E89-4D85-8390-6C703CEC60C0}")
+)
+
+const (
+	S_OK          = 0
+	E_NOINTERFACE = 0x80004002
+	E_POINTER     = 0x80004003
+)
+
+// IMMNotificationClientVtbl - таблица методов, которую вызывает Windows
+type IMMNotificationClientVtbl struct {
+	QueryInterface         uintptr
+	AddRef                 uintptr
+	Release                uintptr
+	OnDeviceStateChanged   uintptr
+	OnDeviceAdded          uintptr
+	OnDeviceRemoved        uintptr
+	OnDefaultDeviceChanged uintptr
+	OnPropertyValueChanged uintptr
+}
+
+// notificationClient - COM-объект IMMNotificationClient, реализованный на Go.
+// Первое поле обязано быть указателем на vtable. Объект живёт, пока на него
+// ссылается AudioManager, поэтому счётчик ссылок нужен только для COM.
+type notificationClient struct {
+	vtbl   *IMMNotificationClientVtbl
+	refs   int32
+	events chan DeviceEvent
+}
+
+// Callback'и создаются один раз на процесс: syscall.NewCallback не освобождает их
+var notificationClientVtbl = &IMMNotificationClientVtbl{
+	QueryInterface:         syscall.NewCallback(ncQueryInterface),
+	AddRef:                 syscall.NewCallback(ncAddRef),
+	Release:                syscall.NewCallback(ncRelease),
+	OnDeviceStateChanged:   syscall.NewCallback(ncOnDeviceStateChanged),
+	OnDeviceAdded:          syscall.NewCallback(ncOnDeviceAdded),
+	OnDeviceRemoved:        syscall.NewCallback(ncOnDeviceRemoved),
+	OnDefaultDeviceChanged: syscall.NewCallback(ncOnDefaultDeviceChanged),
+	OnPropertyValueChanged: syscall.NewCallback(ncOnPropertyValueChanged),
+}
+
+func newNotificationClient() *notificationClient {
+	return &notificationClient{
+		vtbl:   notificationClientVtbl,
+		refs:   1,
+		events: make(chan DeviceEvent, deviceEventBuffer),
+	}
+}
+
+// emit отправляет событие без блокировки: Windows запрещает ждать в callback'ах
+func (nc *notificationClient) emit(event DeviceEvent) {
+	select {
+	case nc.events <- event:
+	default:
+	}
+}
+
+func ncQueryInterface(this *notificationClient, riid *ole.GUID, ppv *unsafe.Pointer) uintptr {
+	if ppv == nil {
+		return E_POINTER
+	}
+	if ole.IsEqualGUID(riid, ole.IID_IUnknown) || ole.IsEqualGUID(riid, IID_IMMNotificationClient) {
+		atomic.AddInt32(&this.refs, 1)
+		*ppv = unsafe.Pointer(this)
+		return S_OK
+	}
+	*ppv = nil
+	return E_NOINTERFACE
+}
+
+func ncAddRef(this *notificationClient) uintptr {
+	return uintptr(atomic.AddInt32(&this.refs, 1))
+}
+
+func ncRelease(this *notificationClient) uintptr {
+	return uintptr(atomic.AddInt32(&this.refs, -1))
+}
+
+func ncOnDeviceStateChanged(this *notificationClient, pwstrDeviceID *uint16, dwNewState uint32) uintptr {
+	deviceID := utf16PtrToString(pwstrDeviceID)
+	this.emit(DeviceEvent{
+		Type:     DeviceStateChanged,
+		DeviceID: deviceID,
+		DataFlow: DataFlowFromID(deviceID),
+		State:    dwNewState,
+	})
+	return S_OK
+}
+
+func ncOnDeviceAdded(this *notificationClient, pwstrDeviceID *uint16) uintptr {
+	deviceID := utf16PtrToString(pwstrDeviceID)
+	this.emit(DeviceEvent{Type: DeviceAdded, DeviceID: deviceID, DataFlow: DataFlowFromID(deviceID)})
+	return S_OK
+}
+
+func ncOnDeviceRemoved(this *notificationClient, pwstrDeviceID *uint16) uintptr {
+	deviceID := utf16PtrToString(pwstrDeviceID)
+	this.emit(DeviceEvent{Type: DeviceRemoved, DeviceID: deviceID, DataFlow: DataFlowFromID(deviceID)})
+	return S_OK
+}
+
+func ncOnDefaultDeviceChanged(this *notificationClient, flow EDataFlow, role ERole, pwstrDefaultDeviceID *uint16) uintptr {
+	this.emit(DeviceEvent{
+		Type:     DefaultDeviceChanged,
+		DeviceID: utf16PtrToString(pwstrDefaultDeviceID),
+		DataFlow: flow,
+		Role:     role,
+	})
+	return S_OK
+}
+
+// ncOnPropertyValueChanged: PROPERTYKEY передаётся по значению, что на x64
+// означает указатель. Изменения свойств нас не интересуют.
+func ncOnPropertyValueChanged(this *notificationClient, pwstrDeviceID *uint16, key uintptr) uintptr {
+	return S_OK
+}
+
+// WatchDevices регистрирует IMMNotificationClient и возвращает канал событий
+func (am *AudioManager) WatchDevices() (<-chan DeviceEvent, error) {
+	if am.notifier != nil {
+		return am.notifier.events, nil
+	}
+
+	client := newNotificationClient()
+	vtbl := (*IMMDeviceEnumeratorVtbl)(unsafe.Pointer(am.enumerator.RawVTable))
+	hr, _, _ := syscall.SyscallN(
+		vtbl.RegisterEndpointNotification,
+		uintptr(unsafe.Pointer(am.enumerator)),
+		uintptr(unsafe.Pointer(client)),
+	)
+	if hr != 0 {
+		return nil, fmt.Errorf("failed to register endpoint notification: %x", hr)
+	}
+
+	am.notifier = client
+	return client.events, nil
+}
+
+// unwatchDevices снимает регистрацию IMMNotificationClient
+func (am *AudioManager) unwatchDevices() {
+	if am.notifier == nil {
+		return
+	}
+
+	vtbl := (*IMMDeviceEnumeratorVtbl)(unsafe.Pointer(am.enumerator.RawVTable))
+	syscall.SyscallN(
+		vtbl.UnregisterEndpointNotification,
+		uintptr(unsafe.Pointer(am.enumerator)),
+		uintptr(unsafe.Pointer(am.notifier)),
+	)
+	am.notifier = nil
+}
@@ -0,0 +1,46 @@
+package audio
+
+// PresenceTracker замечает подключение и уход устройств, сравнивая списки
+// подключённых устройств между вызовами Update. Так подключение видно
+// одинаково по уведомлениям и при опросе, а события, вычитанные пачкой,
+// не теряются. Не потокобезопасен: используется одной горутиной.
+type PresenceTracker struct {
+	known map[EDataFlow]map[string]bool
+}
+
+// NewPresenceTracker создает трекер без запомненных устройств
+func NewPresenceTracker() *PresenceTracker {
+	return &PresenceTracker{known: make(map[EDataFlow]map[string]bool)}
+}
+
+// Update возвращает устройства направления, подключившиеся с прошлого
+// вызова (в порядке перечисления), и ID ушедших. Первый вызов для
+// направления только запоминает подключённые устройства.
+func (t *PresenceTracker) Update(backend Backend, dataFlow EDataFlow) (arrived []AudioDevice, left []string, err error) {
+	devices, err := backend.GetDevices(dataFlow, DEVICE_STATE_ACTIVE)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current := make(map[string]bool, len(devices))
+	for _, dev := range devices {
+		current[dev.ID] = true
+	}
+	known, seen := t.known[dataFlow]
+	t.known[dataFlow] = current
+	if !seen {
+		return nil, nil, nil
+	}
+
+	for _, dev := range devices {
+		if !known[dev.ID] {
+			arrived = append(arrived, dev)
+		}
+	}
+	for id := range known {
+		if !current[id] {
+			left = append(left, id)
+		}
+	}
+	return arrived, left, nil
+}
@@ -0,0 +1,200 @@
+//go:build windows
+
+package audio
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Режимы открытия хранилища свойств
+const (
+	STGM_READ      = 0x0
+	STGM_READWRITE = 0x2
+)
+
+var (
+	procPropVariantClear = modole32.NewProc("PropVariantClear")
+	procCoTaskMemAlloc   = modole32.NewProc("CoTaskMemAlloc")
+	procCoTaskMemFree    = modole32.NewProc("CoTaskMemFree")
+)
+
+// taskMemory - propMemory поверх CoTaskMem: именно так COM размещает
+// содержимое PROPVARIANT, поэтому его можно освободить PropVariantClear
+type taskMemory struct{}
+
+func (taskMemory) read(addr uintptr, n int) ([]byte, error) {
+	if addr == 0 {
+		return nil, fmt.Errorf("invalid memory access: null pointer")
+	}
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	return unsafe.Slice((*byte)(ptr), n), nil
+}
+
+func (taskMemory) alloc(data []byte) (uintptr, error) {
+	addr, _, _ := procCoTaskMemAlloc.Call(uintptr(len(data)))
+	if addr == 0 {
+		return 0, fmt.Errorf("failed to allocate %d bytes", len(data))
+	}
+	ptr := *(*unsafe.Pointer)(unsafe.Pointer(&addr))
+	copy(unsafe.Slice((*byte)(ptr), len(data)), data)
+	return addr, nil
+}
+
+func (taskMemory) free(addr uintptr) {
+	procCoTaskMemFree.Call(addr)
+}
+
+// propVariantClear освобождает содержимое PROPVARIANT системной функцией
+func propVariantClear(pv *PROPVARIANT) {
+	procPropVariantClear.Call(uintptr(unsafe.Pointer(pv)))
+}
+
+// GetDeviceProperty читает свойство устройства из хранилища свойств.
+// Значение имеет тип bool, uint32, string, ole.GUID, []byte или []string;
+// отсутствующее свойство возвращается как nil.
+func (am *AudioManager) GetDeviceProperty(deviceID string, key PROPERTYKEY) (any, error) {
+	device, err := am.getDeviceByID(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer device.Release()
+
+	propStore, err := openPropertyStore(device, STGM_READ)
+	if err != nil {
+		return nil, err
+	}
+	defer propStore.Release()
+
+	return getPropertyValue(propStore, &key)
+}
+
+// SetDeviceProperty записывает свойство устройства. Запись в хранилище
+// свойств конечной точки обычно требует прав администратора.
+func (am *AudioManager) SetDeviceProperty(deviceID string, key PROPERTYKEY, value any) error {
+	device, err := am.getDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	defer device.Release()
+
+	propStore, err := openPropertyStore(device, STGM_READWRITE)
+	if err != nil {
+		return err
+	}
+	defer propStore.Release()
+
+	return setPropertyValue(propStore, &key, value)
+}
+
+// openPropertyStore открывает хранилище свойств устройства
+func openPropertyStore(device *IMMDevice, mode uint32) (*IPropertyStore, error) {
+	vtbl := (*IMMDeviceVtbl)(unsafe.Pointer(device.RawVTable))
+
+	var propStore *IPropertyStore
+	hr, _, _ := syscall.SyscallN(
+		vtbl.OpenPropertyStore,
+		uintptr(unsafe.Pointer(device)),
+		uintptr(mode),
+		uintptr(unsafe.Pointer(&propStore)),
+	)
+	if hr != 0 {
+		return nil, fmt.Errorf("failed to open property store: %x", hr)
+	}
+	return propStore, nil
+}
+
+// getPropertyValue читает и декодирует значение свойства
+func getPropertyValue(propStore *IPropertyStore, key *PROPERTYKEY) (any, error) {
+	vtbl := (*IPropertyStoreVtbl)(unsafe.Pointer(propStore.RawVTable))
+
+	var propVar PROPVARIANT
+	hr, _, _ := syscall.SyscallN(
+		vtbl.GetValue,
+		uintptr(unsafe.Pointer(propStore)),
+		uintptr(unsafe.Pointer(key)),
+		uintptr(unsafe.Pointer(&propVar)),
+	)
+	if hr != 0 {
+		return nil, fmt.Errorf("failed to get property value: %x", hr)
+	}
+	defer propVariantClear(&propVar)
+
+	return decodePropVariant(propVar.bytes(), taskMemory{})
+}
+
+// setPropertyValue кодирует значение и сохраняет его в хранилище свойств
+func setPropertyValue(propStore *IPropertyStore, key *PROPERTYKEY, value any) error {
+	raw, err := encodePropVariant(value, taskMemory{})
+	if err != nil {
+		return err
+	}
+	var propVar PROPVARIANT
+	copy(propVar.bytes(), raw)
+	// SetValue копирует значение, собственную копию освобождаем сразу
+	defer propVariantClear(&propVar)
+
+	vtbl := (*IPropertyStoreVtbl)(unsafe.Pointer(propStore.RawVTable))
+	hr, _, _ := syscall.SyscallN(
+		vtbl.SetValue,
+		uintptr(unsafe.Pointer(propStore)),
+		uintptr(unsafe.Pointer(key)),
+		uintptr(unsafe.Pointer(&propVar)),
+	)
+	if hr != 0 {
+		return fmt.Errorf("failed to set property value: %x", hr)
+	}
+
+	hr, _, _ = syscall.SyscallN(vtbl.Commit, uintptr(unsafe.Pointer(propStore)))
+	if hr != 0 {
+		return fmt.Errorf("failed to commit property store: %x", hr)
+	}
+	return nil
+}
+
+// getDeviceInfo читает имя и сведения об устройстве из хранилища свойств
+func (am *AudioManager) getDeviceInfo(device *IMMDevice) (string, DeviceProperties) {
+	props := DeviceProperties{FormFactor: UnknownFormFactor}
+
+	propStore, err := openPropertyStore(device, STGM_READ)
+	if err != nil {
+		return "Unknown Device", props
+	}
+	defer propStore.Release()
+
+	name := getStringProperty(propStore, &PKEY_Device_FriendlyName)
+	if name == "" {
+		name = "Unknown Device"
+	}
+
+	if value, err := getPropertyValue(propStore, &PKEY_AudioEndpoint_FormFactor); err == nil {
+		if formFactor, ok := value.(uint32); ok {
+			props.FormFactor = EndpointFormFactor(formFactor)
+		}
+	}
+	if value, err := getPropertyValue(propStore, &PKEY_Device_ContainerId); err == nil {
+		if containerID, ok := value.(ole.GUID); ok {
+			props.ContainerID = containerID.String()
+		}
+	}
+	props.Description = getStringProperty(propStore, &PKEY_Device_DeviceDesc)
+	props.InterfaceName = getStringProperty(propStore, &PKEY_DeviceInterface_FriendlyName)
+	props.Enumerator = getStringProperty(propStore, &PKEY_Device_EnumeratorName)
+	props.JackSubType = getStringProperty(propStore, &PKEY_AudioEndpoint_JackSubType)
+	props.IconPath = getStringProperty(propStore, &PKEY_DeviceClass_IconPath)
+
+	return name, props
+}
+
+// getStringProperty возвращает строковое свойство или пустую строку
+func getStringProperty(propStore *IPropertyStore, key *PROPERTYKEY) string {
+	value, err := getPropertyValue(propStore, key)
+	if err != nil {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,376 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// VARTYPE значения, которые поддерживает кодек PROPVARIANT
+const (
+	VT_EMPTY  = 0
+	VT_BOOL   = 11
+	VT_UI4    = 19
+	VT_LPWSTR = 31
+	VT_BLOB   = 65
+	VT_CLSID  = 72
+	VT_VECTOR = 0x1000
+)
+
+// PROPERTYKEY структура
+type PROPERTYKEY struct {
+	Fmtid ole.GUID
+	Pid   uint32
+}
+
+var (
+	PKEY_Device_FriendlyName = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{A45C254E-DF1C-4EFD-8020-67D146A850E0}"),
+		Pid:   14,
+	}
+	PKEY_Device_DeviceDesc = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{A45C254E-DF1C-4EFD-8020-67D146A850E0}"),
+		Pid:   2,
+	}
+	PKEY_Device_EnumeratorName = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{A45C254E-DF1C-4EFD-8020-67D146A850E0}"),
+		Pid:   24,
+	}
+	PKEY_DeviceInterface_FriendlyName = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{026E516E-B814-414B-83CD-856D6FEF4822}"),
+		Pid:   2,
+	}
+	PKEY_Device_ContainerId = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{8C7ED206-3F8A-4827-B3AB-AE9E1FAEFC6C}"),
+		Pid:   2,
+	}
+	PKEY_AudioEndpoint_FormFactor = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{1DA5D803-D492-4EDD-8C23-E0C0FFEE7F0E}"),
+		Pid:   0,
+	}
+	PKEY_AudioEndpoint_JackSubType = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{1DA5D803-D492-4EDD-8C23-E0C0FFEE7F0E}"),
+		Pid:   8,
+	}
+	PKEY_DeviceClass_IconPath = PROPERTYKEY{
+		Fmtid: *ole.NewGUID("{259ABFFC-50A7-47CE-AF08-68C9A7D73366}"),
+		Pid:   12,
+	}
+)
+
+// PROPVARIANT структура. Объединение занимает два указателя: 16 байт на x64,
+// 8 байт на x86, поэтому размер совпадает с системным на обеих архитектурах.
+type PROPVARIANT struct {
+	Vt       uint16
+	Reserved [3]uint16
+	Data     [2]uintptr
+}
+
+const (
+	ptrSize          = int(unsafe.Sizeof(uintptr(0)))
+	propVariantSize  = int(unsafe.Sizeof(PROPVARIANT{}))
+	propVariantValue = 8 // смещение объединения от начала структуры
+	guidSize         = 16
+	variantTrue      = 0xFFFF // VARIANT_TRUE (-1)
+)
+
+// bytes возвращает PROPVARIANT как срез байт для кодека
+func (pv *PROPVARIANT) bytes() []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(pv)), propVariantSize)
+}
+
+// propMemory - память, на которую ссылаются указатели внутри PROPVARIANT.
+// В Windows это CoTaskMem, в FakeBackend - буферы в куче Go, поэтому кодек
+// не разыменовывает указатели сам и работает на любой платформе.
+type propMemory interface {
+	read(addr uintptr, n int) ([]byte, error)
+	alloc(data []byte) (uintptr, error)
+	free(addr uintptr)
+}
+
+// decodePropVariant разбирает PROPVARIANT в значение Go:
+// nil (VT_EMPTY), bool, uint32, string, ole.GUID, []byte или []string
+func decodePropVariant(buf []byte, mem propMemory) (any, error) {
+	if len(buf) < propVariantSize {
+		return nil, fmt.Errorf("PROPVARIANT is too short: %d bytes", len(buf))
+	}
+	vt := binary.LittleEndian.Uint16(buf)
+	data := buf[propVariantValue:]
+
+	switch vt {
+	case VT_EMPTY:
+		return nil, nil
+	case VT_BOOL:
+		return binary.LittleEndian.Uint16(data) != 0, nil
+	case VT_UI4:
+		return binary.LittleEndian.Uint32(data), nil
+	case VT_LPWSTR:
+		return readUTF16(mem, getPtr(data))
+	case VT_CLSID:
+		raw, err := mem.read(getPtr(data), guidSize)
+		if err != nil {
+			return nil, err
+		}
+		return guidFromBytes(raw), nil
+	case VT_BLOB:
+		size := int(binary.LittleEndian.Uint32(data))
+		if size == 0 {
+			return []byte{}, nil
+		}
+		raw, err := mem.read(getPtr(data[ptrSize:]), size)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Clone(raw), nil
+	case VT_VECTOR | VT_LPWSTR:
+		count := int(binary.LittleEndian.Uint32(data))
+		values := make([]string, count)
+		if count == 0 {
+			return values, nil
+		}
+		elems, err := mem.read(getPtr(data[ptrSize:]), count*ptrSize)
+		if err != nil {
+			return nil, err
+		}
+		for i := range values {
+			if values[i], err = readUTF16(mem, getPtr(elems[i*ptrSize:])); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported property type: 0x%x", vt)
+}
+
+// encodePropVariant собирает PROPVARIANT из значения Go. Строки, GUID и
+// массивы размещаются через mem; освобождает их clearPropVariant
+// (или PropVariantClear в Windows).
+func encodePropVariant(value any, mem propMemory) (buf []byte, err error) {
+	buf = make([]byte, propVariantSize)
+	data := buf[propVariantValue:]
+
+	var allocated []uintptr
+	allocate := func(raw []byte) (uintptr, error) {
+		addr, err := mem.alloc(raw)
+		if err == nil {
+			allocated = append(allocated, addr)
+		}
+		return addr, err
+	}
+	defer func() {
+		if err != nil {
+			for _, addr := range allocated {
+				mem.free(addr)
+			}
+		}
+	}()
+
+	var vt uint16
+	switch v := value.(type) {
+	case nil:
+		vt = VT_EMPTY
+	case bool:
+		vt = VT_BOOL
+		if v {
+			binary.LittleEndian.PutUint16(data, variantTrue)
+		}
+	case uint32:
+		vt = VT_UI4
+		binary.LittleEndian.PutUint32(data, v)
+	case string:
+		vt = VT_LPWSTR
+		raw, err := utf16Bytes(v)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := allocate(raw)
+		if err != nil {
+			return nil, err
+		}
+		putPtr(data, addr)
+	case ole.GUID:
+		vt = VT_CLSID
+		addr, err := allocate(guidBytes(v))
+		if err != nil {
+			return nil, err
+		}
+		putPtr(data, addr)
+	case []byte:
+		vt = VT_BLOB
+		binary.LittleEndian.PutUint32(data, uint32(len(v)))
+		if len(v) > 0 {
+			addr, err := allocate(v)
+			if err != nil {
+				return nil, err
+			}
+			putPtr(data[ptrSize:], addr)
+		}
+	case []string:
+		vt = VT_VECTOR | VT_LPWSTR
+		binary.LittleEndian.PutUint32(data, uint32(len(v)))
+		if len(v) > 0 {
+			elems := make([]byte, len(v)*ptrSize)
+			for i, s := range v {
+				raw, err := utf16Bytes(s)
+				if err != nil {
+					return nil, err
+				}
+				addr, err := allocate(raw)
+				if err != nil {
+					return nil, err
+				}
+				putPtr(elems[i*ptrSize:], addr)
+			}
+			addr, err := allocate(elems)
+			if err != nil {
+				return nil, err
+			}
+			putPtr(data[ptrSize:], addr)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported property value type: %T", value)
+	}
+
+	binary.LittleEndian.PutUint16(buf, vt)
+	return buf, nil
+}
+
+// clearPropVariant освобождает память, на которую ссылается PROPVARIANT,
+// и обнуляет его - то же, что делает PropVariantClear для поддерживаемых типов
+func clearPropVariant(buf []byte, mem propMemory) {
+	if len(buf) < propVariantSize {
+		return
+	}
+	vt := binary.LittleEndian.Uint16(buf)
+	data := buf[propVariantValue:]
+
+	switch vt {
+	case VT_LPWSTR, VT_CLSID:
+		if addr := getPtr(data); addr != 0 {
+			mem.free(addr)
+		}
+	case VT_BLOB:
+		if addr := getPtr(data[ptrSize:]); addr != 0 {
+			mem.free(addr)
+		}
+	case VT_VECTOR | VT_LPWSTR:
+		count := int(binary.LittleEndian.Uint32(data))
+		addr := getPtr(data[ptrSize:])
+		if addr == 0 {
+			break
+		}
+		if elems, err := mem.read(addr, count*ptrSize); err == nil {
+			for i := 0; i < count; i++ {
+				if elem := getPtr(elems[i*ptrSize:]); elem != 0 {
+					mem.free(elem)
+				}
+			}
+		}
+		mem.free(addr)
+	}
+	clear(buf[:propVariantSize])
+}
+
+// readUTF16 читает строку UTF-16 до завершающего нуля
+func readUTF16(mem propMemory, addr uintptr) (string, error) {
+	if addr == 0 {
+		return "", nil
+	}
+	var units []uint16
+	for {
+		raw, err := mem.read(addr, 2)
+		if err != nil {
+			return "", err
+		}
+		unit := binary.LittleEndian.Uint16(raw)
+		if unit == 0 {
+			return string(utf16.Decode(units)), nil
+		}
+		units = append(units, unit)
+		addr += 2
+	}
+}
+
+// utf16Bytes кодирует строку в UTF-16LE с завершающим нулём
+func utf16Bytes(s string) ([]byte, error) {
+	if strings.IndexByte(s, 0) >= 0 {
+		return nil, fmt.Errorf("string property contains NUL: %q", s)
+	}
+	units := utf16.Encode([]rune(s))
+	raw := make([]byte, (len(units)+1)*2)
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(raw[i*2:], unit)
+	}
+	return raw, nil
+}
+
+func guidFromBytes(raw []byte) ole.GUID {
+	var guid ole.GUID
+	guid.Data1 = binary.LittleEndian.Uint32(raw[0:])
+	guid.Data2 = binary.LittleEndian.Uint16(raw[4:])
+	guid.Data3 = binary.LittleEndian.Uint16(raw[6:])
+	copy(guid.Data4[:], raw[8:16])
+	return guid
+}
+
+func guidBytes(guid ole.GUID) []byte {
+	raw := make([]byte, guidSize)
+	binary.LittleEndian.PutUint32(raw[0:], guid.Data1)
+	binary.LittleEndian.PutUint16(raw[4:], guid.Data2)
+	binary.LittleEndian.PutUint16(raw[6:], guid.Data3)
+	copy(raw[8:], guid.Data4[:])
+	return raw
+}
+
+func getPtr(b []byte) uintptr {
+	if ptrSize == 8 {
+		return uintptr(binary.LittleEndian.Uint64(b))
+	}
+	return uintptr(binary.LittleEndian.Uint32(b))
+}
+
+func putPtr(b []byte, addr uintptr) {
+	if ptrSize == 8 {
+		binary.LittleEndian.PutUint64(b, uint64(addr))
+		return
+	}
+	binary.LittleEndian.PutUint32(b, uint32(addr))
+}
+
+// heapMemory - propMemory в куче Go. Адреса условные и никогда не
+// разыменовываются, поэтому кодек можно проверять без Windows.
+type heapMemory struct {
+	next   uintptr
+	blocks map[uintptr][]byte
+}
+
+func newHeapMemory() *heapMemory {
+	return &heapMemory{next: 0x10000, blocks: make(map[uintptr][]byte)}
+}
+
+func (m *heapMemory) read(addr uintptr, n int) ([]byte, error) {
+	for base, block := range m.blocks {
+		if addr >= base && addr+uintptr(n) <= base+uintptr(len(block)) {
+			offset := addr - base
+			return block[offset : offset+uintptr(n)], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid memory access: 0x%x (%d bytes)", addr, n)
+}
+
+func (m *heapMemory) alloc(data []byte) (uintptr, error) {
+	addr := m.next
+	m.blocks[addr] = bytes.Clone(data)
+	// Промежуток между блоками ловит чтение за границей
+	m.next += uintptr(len(data)+15)&^7 + 8
+	return addr, nil
+}
+
+func (m *heapMemory) free(addr uintptr) {
+	delete(m.blocks, addr)
+}
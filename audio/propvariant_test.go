@@ -0,0 +1,109 @@
+package audio
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/go-ole/go-ole"
+)
+
+func TestPropVariantRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		vt    uint16
+	}{
+		{"empty", nil, VT_EMPTY},
+		{"bool true", true, VT_BOOL},
+		{"bool false", false, VT_BOOL},
+		{"ui4", uint32(0xDEADBEEF), VT_UI4},
+		{"ui4 zero", uint32(0), VT_UI4},
+		{"lpwstr", "Динамики (Realtek High Definition Audio)", VT_LPWSTR},
+		{"lpwstr empty", "", VT_LPWSTR},
+		{"lpwstr surrogate pair", "🎧 Headphones", VT_LPWSTR},
+		{"clsid", *ole.NewGUID("{1DA5D803-D492-4EDD-8C23-E0C0FFEE7F0E}"), VT_CLSID},
+		{"blob", []byte{0x01, 0x00, 0xFF, 0x7F, 0x80}, VT_BLOB},
+		{"blob empty", []byte{}, VT_BLOB},
+		{"lpwstr vector", []string{"Speakers", "", "Наушники"}, VT_VECTOR | VT_LPWSTR},
+		{"lpwstr vector empty", []string{}, VT_VECTOR | VT_LPWSTR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mem := newHeapMemory()
+			buf, err := encodePropVariant(tt.value, mem)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			if len(buf) != propVariantSize {
+				t.Fatalf("encoded size = %d, want %d", len(buf), propVariantSize)
+			}
+			if vt := binary.LittleEndian.Uint16(buf); vt != tt.vt {
+				t.Errorf("vt = 0x%x, want 0x%x", vt, tt.vt)
+			}
+
+			got, err := decodePropVariant(buf, mem)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.value) {
+				t.Errorf("decoded %#v, want %#v", got, tt.value)
+			}
+
+			clearPropVariant(buf, mem)
+			if len(mem.blocks) != 0 {
+				t.Errorf("%d allocations left after clear", len(mem.blocks))
+			}
+			for i, b := range buf {
+				if b != 0 {
+					t.Fatalf("byte %d = 0x%x after clear, want 0", i, b)
+				}
+			}
+		})
+	}
+}
+
+func TestPropVariantBoolEncoding(t *testing.T) {
+	buf, err := encodePropVariant(true, newHeapMemory())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := binary.LittleEndian.Uint16(buf[propVariantValue:]); v != variantTrue {
+		t.Errorf("VT_BOOL true = 0x%x, want VARIANT_TRUE", v)
+	}
+}
+
+func TestEncodePropVariantFreesOnError(t *testing.T) {
+	mem := newHeapMemory()
+	// Первая строка размещается до того, как вторая окажется ошибочной
+	if _, err := encodePropVariant([]string{"ok", "bad\x00"}, mem); err == nil {
+		t.Fatal("expected error for string with NUL")
+	}
+	if len(mem.blocks) != 0 {
+		t.Errorf("%d allocations left after failed encode", len(mem.blocks))
+	}
+
+	if _, err := encodePropVariant(int64(1), mem); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
+
+func TestDecodePropVariantErrors(t *testing.T) {
+	mem := newHeapMemory()
+	if _, err := decodePropVariant(make([]byte, propVariantSize-1), mem); err == nil {
+		t.Error("expected error for short buffer")
+	}
+
+	buf := make([]byte, propVariantSize)
+	binary.LittleEndian.PutUint16(buf, 3) // VT_I4 не поддерживается
+	if _, err := decodePropVariant(buf, mem); err == nil {
+		t.Error("expected error for unsupported VARTYPE")
+	}
+
+	binary.LittleEndian.PutUint16(buf, VT_LPWSTR)
+	putPtr(buf[propVariantValue:], 0xBAD0)
+	if _, err := decodePropVariant(buf, mem); err == nil {
+		t.Error("expected error for dangling pointer")
+	}
+}
@@ -0,0 +1,268 @@
+package audio
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// RampCurve - форма плавного изменения громкости
+type RampCurve int
+
+const (
+	RampLinear      RampCurve = iota // равномерно по скаляру громкости
+	RampExponential                  // равномерно по децибелам: на слух ровнее
+)
+
+// String возвращает имя кривой, используемое в настройках и во фронтенде
+func (c RampCurve) String() string {
+	switch c {
+	case RampLinear:
+		return "linear"
+	case RampExponential:
+		return "exponential"
+	}
+	return "unknown"
+}
+
+// ParseRampCurve разбирает имя кривой, возвращённое RampCurve.String
+func ParseRampCurve(name string) (RampCurve, bool) {
+	for _, curve := range []RampCurve{RampLinear, RampExponential} {
+		if curve.String() == name {
+			return curve, true
+		}
+	}
+	return 0, false
+}
+
+// Ramp - параметры плавного перехода; нулевая длительность - мгновенная запись
+type Ramp struct {
+	Duration time.Duration
+	Curve    RampCurve
+}
+
+// rampInterval - период записи промежуточной громкости
+const rampInterval = 15 * time.Millisecond
+
+// ErrRamperClosed возвращается после остановки Ramper
+var ErrRamperClosed = errors.New("volume ramper is closed")
+
+// Ramper плавно переводит громкость устройств к цели в собственной горутине
+// со своим бэкендом: COM-объекты привязаны к потоку, который их создал.
+// Новая цель для устройства отменяет его текущий переход.
+type Ramper struct {
+	requests chan rampRequest
+	stop     chan struct{}
+	done     chan struct{}
+
+	// Цели скалярных переходов для фронтенда, пока громкость в пути
+	mu      sync.Mutex
+	targets map[string]float32
+}
+
+// rampRequest - запрос к горутине Ramper; cancel только останавливает переход
+type rampRequest struct {
+	deviceID string
+	level    float32
+	db       bool
+	ramp     Ramp
+	cancel   bool
+	result   chan error
+}
+
+// activeRamp - переход, который сейчас выполняется
+type activeRamp struct {
+	from, to float32
+	db       bool // уровни в децибелах; кривая не применяется
+	ramp     Ramp
+	start    time.Time
+}
+
+// NewRamper запускает горутину переходов с бэкендом из newBackend
+func NewRamper(newBackend func() (Backend, error)) (*Ramper, error) {
+	r := &Ramper{
+		requests: make(chan rampRequest),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		targets:  make(map[string]float32),
+	}
+
+	started := make(chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		backend, err := newBackend()
+		started <- err
+		if err != nil {
+			close(r.done)
+			return
+		}
+		r.run(backend)
+	}()
+
+	if err := <-started; err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close останавливает горутину; начатые переходы обрываются
+func (r *Ramper) Close() {
+	close(r.stop)
+	<-r.done
+}
+
+// SetVolume переводит громкость устройства к level (0.0 - 1.0).
+// Ошибка чтения или первой записи возвращается сразу, дальше переход
+// идёт в фоне; тот же уровень не перезапускает текущий переход.
+func (r *Ramper) SetVolume(deviceID string, level float32, ramp Ramp) error {
+	return r.send(rampRequest{deviceID: deviceID, level: clampVolume(level), ramp: ramp})
+}
+
+// SetVolumeDB переводит громкость устройства к db децибелам;
+// промежуточные уровни идут равномерно по децибелам
+func (r *Ramper) SetVolumeDB(deviceID string, db float32, ramp Ramp) error {
+	return r.send(rampRequest{deviceID: deviceID, level: db, db: true, ramp: ramp})
+}
+
+// Cancel останавливает переход устройства, например перед прямой записью
+func (r *Ramper) Cancel(deviceID string) {
+	r.send(rampRequest{deviceID: deviceID, cancel: true})
+}
+
+// Target возвращает цель скалярного перехода, если он ещё не завершён
+func (r *Ramper) Target(deviceID string) (float32, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	level, ok := r.targets[deviceID]
+	return level, ok
+}
+
+func (r *Ramper) send(req rampRequest) error {
+	req.result = make(chan error, 1)
+	select {
+	case r.requests <- req:
+		return <-req.result
+	case <-r.done:
+		return ErrRamperClosed
+	}
+}
+
+func (r *Ramper) run(backend Backend) {
+	defer close(r.done)
+	defer backend.Close()
+
+	ramps := make(map[string]*activeRamp)
+	ticker := time.NewTicker(rampInterval)
+	ticker.Stop()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case req := <-r.requests:
+			req.result <- r.start(backend, ramps, req)
+		case now := <-ticker.C:
+			r.advance(backend, ramps, now)
+		}
+
+		// Тикер работает, только пока есть переходы
+		if len(ramps) > 0 {
+			ticker.Reset(rampInterval)
+		} else {
+			ticker.Stop()
+		}
+	}
+}
+
+// start начинает переход к новой цели, отменяя предыдущий
+func (r *Ramper) start(backend Backend, ramps map[string]*activeRamp, req rampRequest) error {
+	current, ok := ramps[req.deviceID]
+	if ok && !req.cancel && current.db == req.db && current.to == req.level {
+		return nil
+	}
+	r.remove(ramps, req.deviceID)
+	if req.cancel {
+		return nil
+	}
+
+	var from float32
+	var err error
+	if req.db {
+		from, err = backend.GetDeviceVolumeDB(req.deviceID)
+	} else {
+		from, err = backend.GetDeviceVolume(req.deviceID)
+	}
+	if err != nil {
+		return err
+	}
+	if req.ramp.Duration <= 0 || from == req.level {
+		return writeLevel(backend, req.deviceID, req.level, req.db)
+	}
+
+	ramps[req.deviceID] = &activeRamp{
+		from:  from,
+		to:    req.level,
+		db:    req.db,
+		ramp:  req.ramp,
+		start: time.Now(),
+	}
+	if !req.db {
+		r.mu.Lock()
+		r.targets[req.deviceID] = req.level
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// advance записывает следующий уровень каждого перехода
+func (r *Ramper) advance(backend Backend, ramps map[string]*activeRamp, now time.Time) {
+	for deviceID, ramp := range ramps {
+		level, finished := ramp.level(now)
+		// Устройство могло пропасть: переход к нему больше не нужен
+		if err := writeLevel(backend, deviceID, level, ramp.db); err != nil || finished {
+			r.remove(ramps, deviceID)
+		}
+	}
+}
+
+func (r *Ramper) remove(ramps map[string]*activeRamp, deviceID string) {
+	delete(ramps, deviceID)
+	r.mu.Lock()
+	delete(r.targets, deviceID)
+	r.mu.Unlock()
+}
+
+// level возвращает уровень перехода в момент now и признак завершения
+func (a *activeRamp) level(now time.Time) (float32, bool) {
+	t := float32(now.Sub(a.start)) / float32(a.ramp.Duration)
+	if t >= 1 {
+		return a.to, true
+	}
+	if a.db {
+		return a.from + (a.to-a.from)*t, false
+	}
+	return rampLevel(a.from, a.to, a.ramp.Curve, t), false
+}
+
+// rampLevel интерполирует громкость между from и to (t от 0 до 1).
+// Экспоненциальная кривая идёт равномерно по децибелам типичного
+// диапазона, поэтому громкость на слух меняется с постоянной скоростью.
+func rampLevel(from, to float32, curve RampCurve, t float32) float32 {
+	if curve == RampExponential {
+		fromDB := defaultVolumeRange.ScalarToDB(from)
+		toDB := defaultVolumeRange.ScalarToDB(to)
+		return defaultVolumeRange.DBToScalar(fromDB + (toDB-fromDB)*t)
+	}
+	return from + (to-from)*t
+}
+
+func writeLevel(backend Backend, deviceID string, level float32, db bool) error {
+	if db {
+		return backend.SetDeviceVolumeDB(deviceID, level)
+	}
+	return backend.SetDeviceVolume(deviceID, level)
+}
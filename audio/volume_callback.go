@@ -0,0 +1,167 @@
+//go:build windows
+
+package audio
+
+import (
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// IID для IAudioEndpointVolumeCallback
+var (
+	IID_IAudioEndpointVolumeCallback = ole.NewGUID("{657804FA-D6AD-4496-8A60-352752AF4F89}")
+)
+
+// AUDIO_VOLUME_NOTIFICATION_DATA структура; afChannelVolumes имеет длину nChannels
+type AUDIO_VOLUME_NOTIFICATION_DATA struct {
+	GuidEventContext ole.GUID
+	BMuted           int32
+	FMasterVolume    float32
+	NChannels        uint32
+	AfChannelVolumes [1]float32
+}
+
+// IAudioEndpointVolumeCallbackVtbl - таблица методов, которую вызывает Windows
+type IAudioEndpointVolumeCallbackVtbl struct {
+	QueryInterface uintptr
+	AddRef         uintptr
+	Release        uintptr
+	OnNotify       uintptr
+}
+
+// volumeCallback - COM-объект IAudioEndpointVolumeCallback для одного устройства
+type volumeCallback struct {
+	vtbl     *IAudioEndpointVolumeCallbackVtbl
+	refs     int32
+	deviceID string
+	dataFlow EDataFlow
+	events   chan VolumeEvent
+}
+
+// volumeRegistration - активная подписка на громкость устройства
+type volumeRegistration struct {
+	volume   *IAudioEndpointVolume
+	callback *volumeCallback
+}
+
+var volumeCallbackVtbl = &IAudioEndpointVolumeCallbackVtbl{
+	QueryInterface: syscall.NewCallback(vcQueryInterface),
+	AddRef:         syscall.NewCallback(vcAddRef),
+	Release:        syscall.NewCallback(vcRelease),
+	OnNotify:       syscall.NewCallback(vcOnNotify),
+}
+
+func vcQueryInterface(this *volumeCallback, riid *ole.GUID, ppv *unsafe.Pointer) uintptr {
+	if ppv == nil {
+		return E_POINTER
+	}
+	if ole.IsEqualGUID(riid, ole.IID_IUnknown) || ole.IsEqualGUID(riid, IID_IAudioEndpointVolumeCallback) {
+		atomic.AddInt32(&this.refs, 1)
+		*ppv = unsafe.Pointer(this)
+		return S_OK
+	}
+	*ppv = nil
+	return E_NOINTERFACE
+}
+
+func vcAddRef(this *volumeCallback) uintptr {
+	return uintptr(atomic.AddInt32(&this.refs, 1))
+}
+
+func vcRelease(this *volumeCallback) uintptr {
+	return uintptr(atomic.AddInt32(&this.refs, -1))
+}
+
+func vcOnNotify(this *volumeCallback, data *AUDIO_VOLUME_NOTIFICATION_DATA) uintptr {
+	if data == nil {
+		return S_OK
+	}
+
+	// Копируем каналы: память принадлежит Windows только на время вызова
+	channels := make([]float32, data.NChannels)
+	if data.NChannels > 0 {
+		copy(channels, unsafe.Slice(&data.AfChannelVolumes[0], data.NChannels))
+	}
+
+	event := VolumeEvent{
+		DeviceID: this.deviceID,
+		DataFlow: this.dataFlow,
+		Level:    data.FMasterVolume,
+		Muted:    data.BMuted != 0,
+		Channels: channels,
+
+		EventContext: data.GuidEventContext,
+		Self:         IsSelfContext(&data.GuidEventContext),
+	}
+	select {
+	case this.events <- event:
+	default:
+	}
+	return S_OK
+}
+
+// VolumeEvents возвращает общий канал уведомлений о громкости
+func (am *AudioManager) VolumeEvents() <-chan VolumeEvent {
+	return am.volumeEvents
+}
+
+// WatchVolume регистрирует IAudioEndpointVolumeCallback на устройстве
+func (am *AudioManager) WatchVolume(deviceID string) error {
+	if _, ok := am.volumeWatches[deviceID]; ok {
+		return nil
+	}
+
+	device, err := am.getDeviceByID(deviceID)
+	if err != nil {
+		return err
+	}
+	defer device.Release()
+
+	volume, err := am.getEndpointVolume(device)
+	if err != nil {
+		return err
+	}
+
+	callback := &volumeCallback{
+		vtbl:     volumeCallbackVtbl,
+		refs:     1,
+		deviceID: deviceID,
+		dataFlow: DataFlowFromID(deviceID),
+		events:   am.volumeEvents,
+	}
+
+	vtbl := (*IAudioEndpointVolumeVtbl)(unsafe.Pointer(volume.RawVTable))
+	hr, _, _ := syscall.SyscallN(
+		vtbl.RegisterControlChangeNotify,
+		uintptr(unsafe.Pointer(volume)),
+		uintptr(unsafe.Pointer(callback)),
+	)
+	if hr != 0 {
+		volume.Release()
+		return fmt.Errorf("failed to register volume notification: %x", hr)
+	}
+
+	am.volumeWatches[deviceID] = &volumeRegistration{volume: volume, callback: callback}
+	return nil
+}
+
+// UnwatchVolume снимает регистрацию IAudioEndpointVolumeCallback
+func (am *AudioManager) UnwatchVolume(deviceID string) {
+	reg, ok := am.volumeWatches[deviceID]
+	if !ok {
+		return
+	}
+	delete(am.volumeWatches, deviceID)
+
+	vtbl := (*IAudioEndpointVolumeVtbl)(unsafe.Pointer(reg.volume.RawVTable))
+	syscall.SyscallN(
+		vtbl.UnregisterControlChangeNotify,
+		uintptr(unsafe.Pointer(reg.volume)),
+		uintptr(unsafe.Pointer(reg.callback)),
+	)
+	reg.volume.Release()
+}
@@ -0,0 +1,46 @@
+package audio
+
+import "math"
+
+// VolumeRange - диапазон громкости устройства в децибелах,
+// как его возвращает IAudioEndpointVolume::GetVolumeRange
+type VolumeRange struct {
+	MinDB  float32
+	MaxDB  float32
+	StepDB float32
+}
+
+// defaultVolumeRange - типичный диапазон встроенных динамиков
+var defaultVolumeRange = VolumeRange{MinDB: -65.25, MaxDB: 0, StepDB: 0.03125}
+
+// ClampDB ограничивает уровень диапазоном устройства
+func (r VolumeRange) ClampDB(db float32) float32 {
+	if db < r.MinDB {
+		return r.MinDB
+	}
+	if db > r.MaxDB {
+		return r.MaxDB
+	}
+	return db
+}
+
+// ScalarToDB переводит скалярную громкость (0.0 - 1.0) в децибелы.
+// Windows отображает скаляр на кривую драйвера, которую нельзя прочитать,
+// поэтому используется амплитудная зависимость 20·log10 от верхней границы
+// диапазона: для подсказок во фронтенде, а точное значение даёт
+// GetDeviceVolumeDB.
+func (r VolumeRange) ScalarToDB(scalar float32) float32 {
+	if scalar <= 0 {
+		return r.MinDB
+	}
+	db := r.MaxDB + float32(20*math.Log10(float64(clampVolume(scalar))))
+	return r.ClampDB(db)
+}
+
+// DBToScalar - обратное к ScalarToDB преобразование
+func (r VolumeRange) DBToScalar(db float32) float32 {
+	if db <= r.MinDB {
+		return 0
+	}
+	return clampVolume(float32(math.Pow(10, float64(r.ClampDB(db)-r.MaxDB)/20)))
+}
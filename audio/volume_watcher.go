@@ -0,0 +1,67 @@
+package audio
+
+// VolumeWatcher держит подписку на громкость текущих устройств по умолчанию.
+// Sync нужно вызывать после каждого DeviceEvent: при смене устройства
+// по умолчанию подписка переносится на новое устройство.
+type VolumeWatcher struct {
+	backend Backend
+	watched map[EDataFlow]string
+}
+
+// NewVolumeWatcher создает наблюдатель поверх бэкенда
+func NewVolumeWatcher(backend Backend) *VolumeWatcher {
+	return &VolumeWatcher{
+		backend: backend,
+		watched: make(map[EDataFlow]string),
+	}
+}
+
+// Events возвращает канал уведомлений о громкости
+func (w *VolumeWatcher) Events() <-chan VolumeEvent {
+	return w.backend.VolumeEvents()
+}
+
+// DeviceID возвращает устройство, за которым сейчас ведётся наблюдение
+func (w *VolumeWatcher) DeviceID(dataFlow EDataFlow) string {
+	return w.watched[dataFlow]
+}
+
+// Sync переносит подписки на текущие устройства по умолчанию.
+// Возвращает первую ошибку подписки; остальные направления обрабатываются.
+func (w *VolumeWatcher) Sync() error {
+	var firstErr error
+	current := map[EDataFlow]string{
+		ERender:  w.backend.GetCurrentDefaultOutputID(),
+		ECapture: w.backend.GetCurrentDefaultInputID(),
+	}
+
+	for dataFlow, deviceID := range current {
+		if w.watched[dataFlow] == deviceID {
+			continue
+		}
+		if old := w.watched[dataFlow]; old != "" {
+			w.backend.UnwatchVolume(old)
+			delete(w.watched, dataFlow)
+		}
+		if deviceID == "" {
+			continue
+		}
+		if err := w.backend.WatchVolume(deviceID); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		w.watched[dataFlow] = deviceID
+	}
+
+	return firstErr
+}
+
+// Close снимает все подписки
+func (w *VolumeWatcher) Close() {
+	for dataFlow, deviceID := range w.watched {
+		w.backend.UnwatchVolume(deviceID)
+		delete(w.watched, dataFlow)
+	}
+}
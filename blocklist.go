@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+// allowedDevices - подключённые устройства направления и запреты для них
+type allowedDevices struct {
+	ids     []string          // в порядке перечисления Windows
+	names   map[string]string // имена подключённых устройств
+	blocked []settings.BlockRule
+}
+
+// loadAllowedDevices перечисляет подключённые устройства направления
+func loadAllowedDevices(audioMgr audio.Backend, dataFlow audio.EDataFlow, blocked []settings.BlockRule) (allowedDevices, error) {
+	var devices []audio.AudioDevice
+	var err error
+	if dataFlow == audio.ECapture {
+		devices, err = audioMgr.GetInputDevices()
+	} else {
+		devices, err = audioMgr.GetOutputDevices()
+	}
+	if err != nil {
+		return allowedDevices{}, err
+	}
+
+	result := allowedDevices{names: make(map[string]string, len(devices)), blocked: blocked}
+	for _, dev := range devices {
+		result.ids = append(result.ids, dev.ID)
+		result.names[dev.ID] = dev.Name
+	}
+	return result, nil
+}
+
+// blocks сообщает, запрещено ли устройство для роли
+func (d allowedDevices) blocks(deviceID string, role audio.ERole) bool {
+	return deviceBlocked(d.blocked, deviceID, d.names[deviceID], role)
+}
+
+// allowed сообщает, подключено ли устройство и разрешено ли для роли
+func (d allowedDevices) allowed(deviceID string, role audio.ERole) bool {
+	_, active := d.names[deviceID]
+	return active && !d.blocks(deviceID, role)
+}
+
+// first возвращает первое разрешённое для роли устройство списка
+func (d allowedDevices) first(priority []string, role audio.ERole) string {
+	for _, deviceID := range priority {
+		if d.allowed(deviceID, role) {
+			return deviceID
+		}
+	}
+	return ""
+}
+
+// choose выбирает устройство роли: назначенное ей, затем первое из списка
+// приоритетов. Если ничего не подошло, а current (выбор Windows) запрещён,
+// берётся fallback или первое разрешённое подключённое устройство.
+// Пустая строка - роль не трогать.
+func (d allowedDevices) choose(role audio.ERole, current string, priority []string, roles map[string]string, fallback string) string {
+	if deviceID := roles[role.String()]; d.allowed(deviceID, role) {
+		return deviceID
+	}
+	if deviceID := d.first(priority, role); deviceID != "" {
+		return deviceID
+	}
+	if current == "" || !d.blocks(current, role) {
+		return ""
+	}
+	if d.allowed(fallback, role) {
+		return fallback
+	}
+	return d.first(d.ids, role)
+}
+
+// deviceBlocked сообщает, запрещает ли одно из правил устройство для роли
+func deviceBlocked(rules []settings.BlockRule, deviceID, name string, role audio.ERole) bool {
+	for _, rule := range rules {
+		if rule.Matches(deviceID, name, role.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// rememberAllowedDefault запоминает устройство роли multimedia, если оно
+// разрешено: к нему AutoSound вернётся, когда его вытеснит запрещённое
+func (a *App) rememberAllowedDefault(audioMgr audio.Backend, dataFlow audio.EDataFlow, blocked []settings.BlockRule) {
+	current := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+	devices, err := loadAllowedDevices(audioMgr, dataFlow, blocked)
+	if err != nil || !devices.allowed(current, audio.EMultimedia) {
+		return
+	}
+	if a.allowedDefaults == nil {
+		a.allowedDefaults = make(map[audio.EDataFlow]string)
+	}
+	a.allowedDefaults[dataFlow] = current
+}
+
+// BlockRuleInfo - запрет устройства для фронтенда
+type BlockRuleInfo struct {
+	DeviceID string   `json:"deviceId"`
+	Pattern  string   `json:"pattern"` // шаблон имени: * и ?, без учёта регистра
+	Roles    []string `json:"roles"`   // пусто - все роли
+}
+
+// GetBlockedDevices возвращает запреты направления ("output" или "input")
+func (a *App) GetBlockedDevices(flow string) ([]BlockRuleInfo, error) {
+	dataFlow, ok := parseFlowName(flow)
+	if !ok {
+		return nil, fmt.Errorf("unknown flow %q", flow)
+	}
+	rules := a.blockedDevices(dataFlow)
+	result := make([]BlockRuleInfo, 0, len(rules))
+	for _, rule := range rules {
+		roles := rule.Roles
+		if roles == nil {
+			roles = []string{}
+		}
+		result = append(result, BlockRuleInfo{DeviceID: rule.DeviceID, Pattern: rule.Pattern, Roles: roles})
+	}
+	return result, nil
+}
+
+// SetBlockedDevices заменяет запреты направления. Запрет действует сразу
+// и без автопереключения: если Windows уже выбрала запрещённое устройство,
+// AutoSound его сменит. Ошибочный шаблон имени возвращается ошибкой.
+func (a *App) SetBlockedDevices(flow string, rules []BlockRuleInfo) error {
+	dataFlow, ok := parseFlowName(flow)
+	if !ok {
+		return fmt.Errorf("unknown flow %q", flow)
+	}
+
+	result := make([]settings.BlockRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.DeviceID == "" && rule.Pattern == "" {
+			return fmt.Errorf("block rule %d has neither device ID nor name pattern", i)
+		}
+		if err := settings.CheckPattern(rule.Pattern); err != nil {
+			return fmt.Errorf("block rule %d: invalid name pattern %q: %w", i, rule.Pattern, err)
+		}
+		for _, role := range rule.Roles {
+			if _, ok := audio.ParseRole(role); !ok {
+				return fmt.Errorf("block rule %d: unknown role: %s", i, role)
+			}
+		}
+		var roles []string
+		if len(rule.Roles) > 0 {
+			roles = slices.Clone(rule.Roles)
+		}
+		result = append(result, settings.BlockRule{DeviceID: rule.DeviceID, Pattern: rule.Pattern, Roles: roles})
+	}
+
+	if len(result) == 0 {
+		result = nil
+	}
+	a.settingsMu.Lock()
+	*a.blocklist(dataFlow) = result
+	a.settingsMu.Unlock()
+
+	a.saveSettings()
+	a.wake()
+	return nil
+}
+
+// blockedDevices возвращает запреты направления. Запреты заменяют
+// привязки, а читает горутина уведомлений, поэтому они берутся под
+// settingsMu; список при замене не меняется на месте.
+func (a *App) blockedDevices(dataFlow audio.EDataFlow) []settings.BlockRule {
+	a.settingsMu.Lock()
+	defer a.settingsMu.Unlock()
+	return *a.blocklist(dataFlow)
+}
+
+// blocklist возвращает запреты направления; вызывается под settingsMu
+func (a *App) blocklist(dataFlow audio.EDataFlow) *[]settings.BlockRule {
+	if dataFlow == audio.ECapture {
+		return &a.settings.InputBlocklist
+	}
+	return &a.settings.OutputBlocklist
+}
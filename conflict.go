@@ -0,0 +1,271 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+const (
+	// flapRestores восстановлений за flapWindow означают, что другая
+	// программа или драйвер переключает устройство обратно
+	flapRestores = 5
+	flapWindow   = 30 * time.Second
+	// Пауза в восстановлении при конфликте: удваивается, пока
+	// другая сторона продолжает переключать
+	flapBackoffMin = 15 * time.Second
+	flapBackoffMax = 10 * time.Minute
+)
+
+// flapGuard следит за восстановлениями устройства одного направления
+// и приостанавливает их, если устройство раз за разом переключают обратно
+type flapGuard struct {
+	mu       sync.Mutex
+	restores []time.Time // восстановления в последние flapWindow
+	last     time.Time   // последнее восстановление
+
+	conflict bool
+	since    time.Time
+	backoff  time.Duration
+	retryAt  time.Time
+	count    int    // восстановлений с начала конфликта
+	winnerID string // устройство, которое выставляет другая сторона
+}
+
+// allow сообщает, можно ли сейчас восстанавливать устройство
+func (g *flapGuard) allow(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return !now.Before(g.retryAt)
+}
+
+// restored учитывает восстановление после того, как устройство сменили
+// на winnerID. Возвращает true, если конфликт обнаружен или пауза выросла.
+func (g *flapGuard) restored(now time.Time, winnerID string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.winnerID = winnerID
+	g.last = now
+	g.restores = slices.DeleteFunc(g.restores, func(t time.Time) bool {
+		return now.Sub(t) > flapWindow
+	})
+	g.restores = append(g.restores, now)
+	if g.conflict {
+		g.count++
+	}
+
+	// После паузы хватает одного повторного отката
+	threshold := flapRestores
+	if g.conflict {
+		threshold = 2
+	}
+	if len(g.restores) < threshold {
+		return false
+	}
+
+	if g.conflict {
+		g.backoff = min(g.backoff*2, flapBackoffMax)
+	} else {
+		g.conflict = true
+		g.since = now
+		g.backoff = flapBackoffMin
+		g.count = len(g.restores)
+	}
+	g.retryAt = now.Add(g.backoff)
+	g.restores = nil
+	return true
+}
+
+// settled учитывает проверку без восстановления. Возвращает true, если
+// конфликт закончился: устройство держится flapWindow после восстановления.
+func (g *flapGuard) settled(now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.conflict || now.Before(g.retryAt) || now.Sub(g.last) < flapWindow {
+		return false
+	}
+	g.clear()
+	return true
+}
+
+// wakeup возвращает ближайший срок, когда конфликт нужно проверить:
+// конец паузы в восстановлении или момент, когда его можно счесть
+// законченным. false - ждать нечего.
+func (g *flapGuard) wakeup(now time.Time) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.conflict {
+		return time.Time{}, false
+	}
+	if now.Before(g.retryAt) {
+		return g.retryAt, true
+	}
+	if settle := g.last.Add(flapWindow); now.Before(settle) {
+		return settle, true
+	}
+	return time.Time{}, false
+}
+
+// reset забывает конфликт, например после смены сохранённых устройств
+func (g *flapGuard) reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.clear()
+}
+
+// clear обнуляет состояние; вызывается под g.mu
+func (g *flapGuard) clear() {
+	g.restores = nil
+	g.last = time.Time{}
+	g.conflict = false
+	g.since = time.Time{}
+	g.backoff = 0
+	g.retryAt = time.Time{}
+	g.count = 0
+	g.winnerID = ""
+}
+
+// DeviceConflict - обнаруженная борьба за устройство по умолчанию
+type DeviceConflict struct {
+	Flow       string `json:"flow"` // output или input
+	WinnerID   string `json:"winnerId"`
+	WinnerName string `json:"winnerName"`
+	Restores   int    `json:"restores"` // восстановлений с начала конфликта
+	Since      int64  `json:"since"`    // Unix-время в миллисекундах
+	RetryAt    int64  `json:"retryAt"`  // следующая попытка восстановления
+}
+
+// status возвращает конфликт для фронтенда; false - конфликта нет
+func (g *flapGuard) status(flow string) (DeviceConflict, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if !g.conflict {
+		return DeviceConflict{}, false
+	}
+	return DeviceConflict{
+		Flow:     flow,
+		WinnerID: g.winnerID,
+		Restores: g.count,
+		Since:    g.since.UnixMilli(),
+		RetryAt:  g.retryAt.UnixMilli(),
+	}, true
+}
+
+// GetDeviceConflicts возвращает направления, где AutoSound
+// приостановил восстановление из-за конфликта
+func (a *App) GetDeviceConflicts() []DeviceConflict {
+	var conflicts []DeviceConflict
+	for _, flow := range []struct {
+		name     string
+		dataFlow audio.EDataFlow
+		guard    *flapGuard
+	}{
+		{"output", audio.ERender, &a.outputConflict},
+		{"input", audio.ECapture, &a.inputConflict},
+	} {
+		conflict, ok := flow.guard.status(flow.name)
+		if !ok {
+			continue
+		}
+		conflict.WinnerName = a.deviceName(flow.dataFlow, conflict.WinnerID)
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// deviceName возвращает имя устройства или пустую строку
+func (a *App) deviceName(dataFlow audio.EDataFlow, deviceID string) string {
+	if a.audioManager == nil {
+		return ""
+	}
+	return lookupDeviceName(a.audioManager, dataFlow, deviceID)
+}
+
+// lookupDeviceName ищет имя устройства через audioMgr
+func lookupDeviceName(audioMgr audio.Backend, dataFlow audio.EDataFlow, deviceID string) string {
+	if deviceID == "" {
+		return ""
+	}
+	devices, err := audioMgr.GetDevices(dataFlow, audio.DEVICE_STATEMASK_ALL)
+	if err != nil {
+		return ""
+	}
+	for _, dev := range devices {
+		if dev.ID == deviceID {
+			return dev.Name
+		}
+	}
+	return ""
+}
+
+// enforceFlowDevices восстанавливает устройства направления, пока нет
+// конфликта. Если устройство раз за разом переключают обратно,
+// восстановление приостанавливается с растущей паузой.
+func (a *App) enforceFlowDevices(audioMgr audio.Backend, dataFlow audio.EDataFlow, kind string, guard *flapGuard, last *string, priority []string, roles map[string]string, blocked []settings.BlockRule) {
+	now := time.Now()
+	if !guard.allow(now) {
+		return
+	}
+	if a.currentSettings().LearnMode && a.learnExternalChoice(audioMgr, dataFlow, kind, last, priority, roles, blocked) {
+		return
+	}
+
+	before := make(map[audio.ERole]string, len(audio.Roles))
+	for _, role := range audio.Roles {
+		before[role] = audioMgr.GetDefaultDeviceID(dataFlow, role)
+	}
+
+	previous := *last
+	resolved, restored, err := applyRoleDevices(audioMgr, dataFlow, priority, roles, blocked, a.allowedDefaults[dataFlow])
+	a.reportFallback(kind, last, resolved, priority)
+	if err != nil {
+		log.Printf("Failed to restore %s device: %v", strings.ToLower(kind), err)
+	}
+	if len(blocked) > 0 {
+		a.rememberAllowedDefault(audioMgr, dataFlow, blocked)
+	}
+
+	if len(restored) == 0 {
+		if guard.settled(now) {
+			log.Printf("%s: device conflict resolved", kind)
+			a.setTrayStatus(conflictTrayKeys[dataFlow], "")
+		}
+		return
+	}
+
+	log.Printf("%s device changed externally, restored roles %v", kind, restored)
+	// Конфликтом считаются только переключения с подключённого устройства,
+	// выбранного и при прошлой проверке. Переход на запасное после
+	// отключения и возврат предпочтительного к конфликту не ведут.
+	if resolved != previous {
+		return
+	}
+	winnerID := before[restored[0]]
+	if guard.restored(now, winnerID) {
+		conflict, _ := guard.status("")
+		log.Printf("%s: device conflict with %s detected, pausing restore until %s",
+			kind, winnerID, time.UnixMilli(conflict.RetryAt).Format(time.TimeOnly))
+		a.setTrayStatus(conflictTrayKeys[dataFlow], conflictTrayTexts[dataFlow])
+	}
+}
+
+// Строки трея о конфликте для каждого направления
+var (
+	conflictTrayKeys = map[audio.EDataFlow]string{
+		audio.ERender:  trayConflictOutput,
+		audio.ECapture: trayConflictInput,
+	}
+	conflictTrayTexts = map[audio.EDataFlow]string{
+		audio.ERender:  "Конфликт: устройство вывода переключает другая программа",
+		audio.ECapture: "Конфликт: микрофон переключает другая программа",
+	}
+)
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"AutoSoundWindows/audio"
+)
+
+func TestEnforceFlowDevicesCountsOnlyExternalSwitches(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	fake.AddDevice("usb", "USB Headset", audio.ERender, 0.5)
+	priority := []string{"usb", "headphones"}
+	var guard flapGuard
+	var last string
+	enforce := func() {
+		a.enforceFlowDevices(fake, audio.ERender, "Output", &guard, &last, priority, nil, nil)
+	}
+	enforce()
+
+	// Отключение и возврат предпочтительного устройства конфликтом не считаются
+	for range flapRestores + 1 {
+		fake.RemoveDevice("usb")
+		enforce()
+		fake.AddDevice("usb", "USB Headset", audio.ERender, 0.5)
+		enforce()
+	}
+	if got := fake.GetCurrentDefaultOutputID(); got != "usb" {
+		t.Fatalf("default output = %q, want usb", got)
+	}
+	if _, ok := guard.status("output"); ok {
+		t.Fatal("fallback and promotion are counted as a conflict")
+	}
+
+	// Другая программа раз за разом уводит роли с подключённого устройства
+	for range flapRestores {
+		fake.SetDefaultDevice("speakers")
+		enforce()
+	}
+	conflict, ok := guard.status("output")
+	if !ok {
+		t.Fatal("external switches are not detected as a conflict")
+	}
+	if conflict.WinnerID != "speakers" || conflict.Restores != flapRestores {
+		t.Errorf("conflict = %+v, want winner speakers after %d restores", conflict, flapRestores)
+	}
+}
+
+func TestFlapGuard(t *testing.T) {
+	type step struct {
+		at      time.Duration // от начала
+		restore bool          // restored, иначе settled
+		want    bool          // результат вызова
+		backoff time.Duration // пауза после вызова
+	}
+	s := time.Second
+	tests := []struct {
+		name         string
+		steps        []step
+		wantConflict bool
+	}{
+		{
+			name: "below threshold",
+			steps: []step{
+				{0, true, false, 0}, {1 * s, true, false, 0}, {2 * s, true, false, 0}, {3 * s, true, false, 0},
+			},
+		},
+		{
+			name: "threshold",
+			steps: []step{
+				{0, true, false, 0}, {1 * s, true, false, 0}, {2 * s, true, false, 0}, {3 * s, true, false, 0},
+				{4 * s, true, true, flapBackoffMin},
+			},
+			wantConflict: true,
+		},
+		{
+			name: "restores outside the window expire",
+			steps: []step{
+				{0, true, false, 0}, {1 * s, true, false, 0}, {2 * s, true, false, 0}, {3 * s, true, false, 0},
+				{40 * s, true, false, 0},
+			},
+		},
+		{
+			name: "backoff doubles up to the maximum",
+			steps: []step{
+				{0, true, false, 0}, {1 * s, true, false, 0}, {2 * s, true, false, 0}, {3 * s, true, false, 0},
+				{4 * s, true, true, 15 * s},
+				// После паузы хватает двух откатов подряд
+				{19 * s, true, false, 15 * s}, {20 * s, true, true, 30 * s},
+				{50 * s, true, false, 30 * s}, {51 * s, true, true, time.Minute},
+				{111 * s, true, false, time.Minute}, {112 * s, true, true, 2 * time.Minute},
+				{232 * s, true, false, 2 * time.Minute}, {233 * s, true, true, 4 * time.Minute},
+				{473 * s, true, false, 4 * time.Minute}, {474 * s, true, true, 8 * time.Minute},
+				{954 * s, true, false, 8 * time.Minute}, {955 * s, true, true, flapBackoffMax},
+				{1555 * s, true, false, flapBackoffMax}, {1556 * s, true, true, flapBackoffMax},
+			},
+			wantConflict: true,
+		},
+		{
+			name: "settled after the window without restores",
+			steps: []step{
+				{0, true, false, 0}, {1 * s, true, false, 0}, {2 * s, true, false, 0}, {3 * s, true, false, 0},
+				{4 * s, true, true, 15 * s},
+				{10 * s, false, false, 15 * s}, // пауза ещё идёт
+				{30 * s, false, false, 15 * s}, // восстановление было недавно
+				{34 * s, false, true, 0},
+				{35 * s, false, false, 0},
+			},
+		},
+		{
+			name: "settled without conflict",
+			steps: []step{
+				{0, true, false, 0}, {time.Minute, false, false, 0},
+			},
+		},
+	}
+
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var g flapGuard
+			for _, st := range tt.steps {
+				now := start.Add(st.at)
+				var got bool
+				if st.restore {
+					got = g.restored(now, "speakers")
+				} else {
+					got = g.settled(now)
+				}
+				if got != st.want {
+					t.Fatalf("at %v: result = %v, want %v", st.at, got, st.want)
+				}
+				if g.backoff != st.backoff {
+					t.Fatalf("at %v: backoff = %v, want %v", st.at, g.backoff, st.backoff)
+				}
+			}
+			if _, ok := g.status("output"); ok != tt.wantConflict {
+				t.Errorf("conflict = %v, want %v", ok, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestFlapGuardWakeup(t *testing.T) {
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	var g flapGuard
+	if _, ok := g.wakeup(start); ok {
+		t.Error("wakeup without conflict")
+	}
+	for i := range flapRestores {
+		g.restored(start.Add(time.Duration(i)*time.Second), "speakers")
+	}
+	last := start.Add((flapRestores - 1) * time.Second)
+
+	tests := []struct {
+		now    time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{last, last.Add(flapBackoffMin), true},
+		{last.Add(flapBackoffMin), last.Add(flapWindow), true},
+		{last.Add(flapWindow), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := g.wakeup(tt.now)
+		if ok != tt.wantOK || !got.Equal(tt.want) {
+			t.Errorf("wakeup(%v) = %v, %v; want %v, %v", tt.now.Sub(start), got.Sub(start), ok, tt.want.Sub(start), tt.wantOK)
+		}
+	}
+}
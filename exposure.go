@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/exposure"
+	"AutoSoundWindows/settings"
+)
+
+const (
+	// exposureFileName - файл дневной истории нагрузки рядом с настройками
+	exposureFileName = "exposure.json"
+	// exposureHistoryDays - сколько дней истории показывает фронтенд
+	exposureHistoryDays = 7
+	// exposureLowerDB - на сколько снижается громкость при превышении бюджета:
+	// -10 dB в десять раз уменьшают прирост дозы
+	exposureLowerDB = 10
+	// maxExposureBudgetMinutes - самый большой бюджет, который можно задать
+	maxExposureBudgetMinutes = 24 * 60
+)
+
+// Действия при превышении дневного бюджета
+const (
+	exposureWarn  = "warn"
+	exposureLower = "lower"
+)
+
+// exposureDevice - то, что учёт нагрузки знает об устройстве вывода
+type exposureDevice struct {
+	name       string
+	headphones bool
+}
+
+// newExposureTracker загружает историю нагрузки; без менеджера настроек
+// история ведётся только в памяти
+func (a *App) newExposureTracker() *exposure.Tracker {
+	var filePath string
+	if a.settingsManager != nil {
+		filePath = filepath.Join(filepath.Dir(a.settingsManager.GetFilePath()), exposureFileName)
+	}
+	tracker, err := exposure.NewTracker(filePath)
+	if err != nil {
+		log.Printf("Failed to load exposure history: %v", err)
+	}
+	return tracker
+}
+
+// trackExposure замеряет устройство вывода по умолчанию. Учитываются только
+// наушники и гарнитуры; время без звука в нагрузку не входит.
+func (a *App) trackExposure(audioMgr audio.Backend) {
+	if a.exposure == nil {
+		return
+	}
+	now := time.Now()
+	deviceID := audioMgr.GetDefaultDeviceID(audio.ERender, audio.EMultimedia)
+	device := a.exposureDevice(audioMgr, deviceID)
+
+	state := exposure.State{DeviceID: deviceID, Name: device.name}
+	if device.headphones {
+		muted, _ := audioMgr.GetDeviceMute(deviceID)
+		db, dbErr := audioMgr.GetDeviceVolumeDB(deviceID)
+		r, rangeErr := audioMgr.GetVolumeRange(deviceID)
+		state.Listening = !muted && dbErr == nil && rangeErr == nil
+		state.AttenuationDB = db - r.MaxDB
+	}
+	a.exposure.Update(now, state)
+	// Предупреждение относится к выбранным наушникам и к сегодняшнему дню
+	if !a.exposure.Day(now, deviceID).Exceeded {
+		a.setTrayStatus(trayExposure, "")
+	}
+
+	if state.Listening {
+		a.checkExposureBudget(audioMgr, deviceID, now)
+	}
+	if err := a.exposure.SaveIfDue(now); err != nil {
+		log.Printf("Failed to save exposure history: %v", err)
+	}
+}
+
+// exposureDevice возвращает имя и форм-фактор устройства вывода,
+// запоминая их: свойства не меняются, а перечисление устройств дорогое
+func (a *App) exposureDevice(audioMgr audio.Backend, deviceID string) exposureDevice {
+	if deviceID == "" {
+		return exposureDevice{}
+	}
+	if device, ok := a.exposureDevices[deviceID]; ok {
+		return device
+	}
+
+	devices, err := audioMgr.GetDevices(audio.ERender, audio.DEVICE_STATE_ACTIVE)
+	if err != nil {
+		return exposureDevice{}
+	}
+	for _, d := range devices {
+		if d.ID != deviceID {
+			continue
+		}
+		device := exposureDevice{
+			name:       d.Name,
+			headphones: d.FormFactor == audio.Headphones || d.FormFactor == audio.Headset,
+		}
+		if a.exposureDevices == nil {
+			a.exposureDevices = make(map[string]exposureDevice)
+		}
+		a.exposureDevices[deviceID] = device
+		return device
+	}
+	return exposureDevice{}
+}
+
+// checkExposureBudget реагирует на превышение дневного бюджета один раз
+// за день: предупреждает или снижает громкость
+func (a *App) checkExposureBudget(audioMgr audio.Backend, deviceID string, now time.Time) {
+	current := a.currentSettings()
+	budget := current.ExposureBudgetMinutes
+	if budget <= 0 {
+		return
+	}
+	day := a.exposure.Day(now, deviceID)
+	if day.Exceeded || day.Dose < float64(budget)*60 {
+		return
+	}
+	a.exposure.MarkExceeded(now, deviceID)
+
+	log.Printf("Daily exposure budget of %s exceeded (%.0f of %d min)", deviceID, day.Dose/60, budget)
+	a.setTrayStatus(trayExposure, "Дневная доза звука в наушниках превышена")
+	if current.ExposureAction == exposureLower {
+		a.lowerForExposure(audioMgr, deviceID)
+	}
+}
+
+// lowerForExposure снижает громкость на exposureLowerDB и запоминает
+// новую громкость, чтобы фиксация не вернула прежнюю
+func (a *App) lowerForExposure(audioMgr audio.Backend, deviceID string) {
+	db, err := audioMgr.GetDeviceVolumeDB(deviceID)
+	if err != nil {
+		return
+	}
+	a.cancelRamp(deviceID)
+	if err := audioMgr.SetDeviceVolumeDB(deviceID, db-exposureLowerDB); err != nil {
+		log.Printf("Failed to lower volume of %s: %v", deviceID, err)
+		return
+	}
+
+	level, levelErr := audioMgr.GetDeviceVolume(deviceID)
+	actualDB, dbErr := audioMgr.GetDeviceVolumeDB(deviceID)
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		if levelErr == nil {
+			dev.Volume = ptrTo(level)
+		}
+		if dev.VolumeDB != nil && dbErr == nil {
+			dev.VolumeDB = ptrTo(actualDB)
+		}
+	})
+}
+
+// saveExposure сохраняет накопленную нагрузку при остановке
+func (a *App) saveExposure() {
+	if a.exposure == nil {
+		return
+	}
+	now := time.Now()
+	// Засчитываем время с последнего замера
+	a.exposure.Update(now, a.exposure.Current())
+	if err := a.exposure.Save(now); err != nil {
+		log.Printf("Failed to save exposure history: %v", err)
+	}
+}
+
+// ExposureDay - нагрузка устройства за день для фронтенда
+type ExposureDay struct {
+	Date        string  `json:"date"`
+	DeviceID    string  `json:"deviceId"`
+	Name        string  `json:"name"`
+	Minutes     float64 `json:"minutes"`
+	DoseMinutes float64 `json:"doseMinutes"` // минуты на полной громкости
+	Exceeded    bool    `json:"exceeded"`
+}
+
+// ExposureStats - нагрузка на текущее устройство вывода сегодня и история
+type ExposureStats struct {
+	Today         ExposureDay   `json:"today"`
+	Listening     bool          `json:"listening"` // выбраны наушники и звук включён
+	BudgetMinutes int           `json:"budgetMinutes"`
+	History       []ExposureDay `json:"history"` // новые дни первыми
+}
+
+// GetExposureStats возвращает дневную нагрузку на наушники
+func (a *App) GetExposureStats() ExposureStats {
+	stats := ExposureStats{BudgetMinutes: a.currentSettings().ExposureBudgetMinutes}
+	if a.exposure == nil {
+		return stats
+	}
+	now := time.Now()
+	current := a.exposure.Current()
+	stats.Listening = current.Listening
+	if current.DeviceID != "" {
+		stats.Today = exposureDay(a.exposure.Day(now, current.DeviceID))
+		stats.Today.Name = current.Name
+	}
+	for _, record := range a.exposure.History(now, exposureHistoryDays) {
+		stats.History = append(stats.History, exposureDay(record))
+	}
+	return stats
+}
+
+func exposureDay(record exposure.Record) ExposureDay {
+	return ExposureDay{
+		Date:        record.Date,
+		DeviceID:    record.DeviceID,
+		Name:        record.Name,
+		Minutes:     record.Seconds / 60,
+		DoseMinutes: record.Dose / 60,
+		Exceeded:    record.Exceeded,
+	}
+}
+
+// ExposureBudget - дневной бюджет нагрузки для фронтенда
+type ExposureBudget struct {
+	Minutes int    `json:"minutes"` // на полной громкости; 0 - без ограничения
+	Action  string `json:"action"`  // warn или lower
+}
+
+// GetExposureBudget возвращает дневной бюджет нагрузки
+func (a *App) GetExposureBudget() ExposureBudget {
+	current := a.currentSettings()
+	action := current.ExposureAction
+	if action == "" {
+		action = exposureWarn
+	}
+	return ExposureBudget{Minutes: current.ExposureBudgetMinutes, Action: action}
+}
+
+// SetExposureBudget задаёт дневной бюджет нагрузки и действие при превышении
+func (a *App) SetExposureBudget(budget ExposureBudget) error {
+	if budget.Minutes < 0 || budget.Minutes > maxExposureBudgetMinutes {
+		return fmt.Errorf("exposure budget %d min is out of range 0..%d", budget.Minutes, maxExposureBudgetMinutes)
+	}
+	if budget.Action != exposureWarn && budget.Action != exposureLower {
+		return fmt.Errorf("unknown exposure action %q", budget.Action)
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.ExposureBudgetMinutes = budget.Minutes
+		s.ExposureAction = budget.Action
+	})
+	return nil
+}
@@ -0,0 +1,215 @@
+// Package exposure накапливает дневную звуковую нагрузку на наушники
+package exposure
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// historyDays - сколько дней хранится в файле истории
+	historyDays = 90
+	// maxSampleGap - самый долгий промежуток между замерами, который
+	// засчитывается целиком: дольше - компьютер спал или замеры терялись
+	maxSampleGap = time.Minute
+	// saveInterval - как часто накопленное сохраняется в файл
+	saveInterval = time.Minute
+	// dateLayout - формат даты записи
+	dateLayout = "2006-01-02"
+)
+
+// Record - нагрузка одного устройства за один день
+type Record struct {
+	Date     string `json:"date"` // YYYY-MM-DD по местному времени
+	DeviceID string `json:"device_id"`
+	Name     string `json:"name"`
+	// Время, пока устройство было выбрано и звук не был выключен
+	Seconds float64 `json:"seconds"`
+	// Доза: секунды, приведённые к полной громкости по энергии
+	// (каждые -3 dB вдвое уменьшают вклад)
+	Dose float64 `json:"dose"`
+	// Дневной бюджет превышен и AutoSound уже отреагировал
+	Exceeded bool `json:"exceeded,omitempty"`
+}
+
+// State - состояние устройства вывода на момент замера
+type State struct {
+	DeviceID string
+	Name     string
+	// Listening - наушники выбраны и звук не выключен
+	Listening bool
+	// AttenuationDB - громкость относительно максимума устройства (<= 0)
+	AttenuationDB float32
+}
+
+// Tracker накапливает нагрузку между замерами. Состояние, переданное
+// в Update, считается действовавшим до следующего вызова Update.
+type Tracker struct {
+	mu       sync.Mutex
+	filePath string
+	records  []Record
+	current  State
+	since    time.Time
+	dirty    bool
+	saved    time.Time
+}
+
+// NewTracker загружает историю из filePath; пустой путь - без файла
+func NewTracker(filePath string) (*Tracker, error) {
+	t := &Tracker{filePath: filePath}
+	if filePath == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return t, nil
+		}
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t.records); err != nil {
+		return t, err
+	}
+	return t, nil
+}
+
+// Update засчитывает прежнее состояние до now и запоминает новое
+func (t *Tracker) Update(now time.Time, state State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.since.IsZero() && t.current.Listening && now.After(t.since) {
+		from := t.since
+		if now.Sub(from) > maxSampleGap {
+			from = now.Add(-maxSampleGap)
+		}
+		t.accumulate(from, now)
+	}
+	t.current = state
+	t.since = now
+}
+
+// accumulate добавляет отрезок [from, to), разбивая его по дням
+func (t *Tracker) accumulate(from, to time.Time) {
+	weight := math.Pow(10, float64(min(t.current.AttenuationDB, 0))/10)
+	for from.Before(to) {
+		y, m, d := from.Date()
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, from.Location())
+		if end.After(to) {
+			end = to
+		}
+		seconds := end.Sub(from).Seconds()
+		record := t.record(from.Format(dateLayout), t.current.DeviceID)
+		if t.current.Name != "" {
+			record.Name = t.current.Name
+		}
+		record.Seconds += seconds
+		record.Dose += seconds * weight
+		t.dirty = true
+		from = end
+	}
+}
+
+// record возвращает запись дня, создавая её при необходимости
+func (t *Tracker) record(date, deviceID string) *Record {
+	for i := range t.records {
+		if t.records[i].Date == date && t.records[i].DeviceID == deviceID {
+			return &t.records[i]
+		}
+	}
+	t.records = append(t.records, Record{Date: date, DeviceID: deviceID})
+	return &t.records[len(t.records)-1]
+}
+
+// Current возвращает состояние последнего замера
+func (t *Tracker) Current() State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.current
+}
+
+// Day возвращает запись устройства за день, в который попадает now
+func (t *Tracker) Day(now time.Time, deviceID string) Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	date := now.Format(dateLayout)
+	for _, record := range t.records {
+		if record.Date == date && record.DeviceID == deviceID {
+			return record
+		}
+	}
+	return Record{Date: date, DeviceID: deviceID}
+}
+
+// MarkExceeded отмечает, что на превышение бюджета за день уже отреагировали
+func (t *Tracker) MarkExceeded(now time.Time, deviceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.record(now.Format(dateLayout), deviceID).Exceeded = true
+	t.dirty = true
+}
+
+// History возвращает записи за последние days дней, новые первыми
+func (t *Tracker) History(now time.Time, days int) []Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := now.AddDate(0, 0, -days+1).Format(dateLayout)
+	var history []Record
+	for _, record := range t.records {
+		if record.Date >= first {
+			history = append(history, record)
+		}
+	}
+	slices.SortStableFunc(history, func(a, b Record) int {
+		switch {
+		case a.Date > b.Date:
+			return -1
+		case a.Date < b.Date:
+			return 1
+		}
+		return 0
+	})
+	return history
+}
+
+// SaveIfDue сохраняет историю, если она изменилась и с прошлого
+// сохранения прошло saveInterval
+func (t *Tracker) SaveIfDue(now time.Time) error {
+	t.mu.Lock()
+	due := t.dirty && now.Sub(t.saved) >= saveInterval
+	t.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return t.Save(now)
+}
+
+// Save записывает историю в файл, отбрасывая дни старше historyDays
+func (t *Tracker) Save(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	first := now.AddDate(0, 0, -historyDays+1).Format(dateLayout)
+	t.records = slices.DeleteFunc(t.records, func(record Record) bool {
+		return record.Date < first
+	})
+	t.dirty = false
+	t.saved = now
+	if t.filePath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.filePath, data, 0644)
+}
@@ -0,0 +1,123 @@
+package exposure
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestTrackerUpdate(t *testing.T) {
+	start := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	beforeMidnight := time.Date(2024, 3, 10, 23, 59, 30, 0, time.UTC)
+	headphones := State{DeviceID: "hp", Name: "Headphones", Listening: true}
+	quiet := State{DeviceID: "hp", Name: "Headphones", Listening: true, AttenuationDB: -10}
+	muted := State{DeviceID: "hp", Name: "Headphones"}
+	earbuds := State{DeviceID: "buds", Name: "Earbuds", Listening: true}
+
+	type sample struct {
+		at    time.Duration // от from
+		state State
+	}
+	tests := []struct {
+		name    string
+		from    time.Time
+		samples []sample
+		want    []Record
+	}{
+		{
+			name:    "full volume",
+			from:    start,
+			samples: []sample{{0, headphones}, {30 * time.Second, muted}},
+			want:    []Record{{Date: "2024-03-10", DeviceID: "hp", Name: "Headphones", Seconds: 30, Dose: 30}},
+		},
+		{
+			name:    "attenuation weights dose by energy",
+			from:    start,
+			samples: []sample{{0, quiet}, {40 * time.Second, muted}},
+			want:    []Record{{Date: "2024-03-10", DeviceID: "hp", Name: "Headphones", Seconds: 40, Dose: 4}},
+		},
+		{
+			name: "positive gain counts as full volume",
+			from: start,
+			samples: []sample{
+				{0, State{DeviceID: "hp", Listening: true, AttenuationDB: 6}},
+				{10 * time.Second, muted},
+			},
+			want: []Record{{Date: "2024-03-10", DeviceID: "hp", Seconds: 10, Dose: 10}},
+		},
+		{
+			name:    "muted time is not counted",
+			from:    start,
+			samples: []sample{{0, muted}, {30 * time.Second, headphones}},
+			want:    nil,
+		},
+		{
+			name:    "long gap is capped",
+			from:    start,
+			samples: []sample{{0, headphones}, {10 * time.Minute, muted}},
+			want:    []Record{{Date: "2024-03-10", DeviceID: "hp", Name: "Headphones", Seconds: 60, Dose: 60}},
+		},
+		{
+			name: "device switch",
+			from: start,
+			samples: []sample{
+				{0, headphones},
+				{20 * time.Second, earbuds},
+				{30 * time.Second, muted},
+			},
+			want: []Record{
+				{Date: "2024-03-10", DeviceID: "hp", Name: "Headphones", Seconds: 20, Dose: 20},
+				{Date: "2024-03-10", DeviceID: "buds", Name: "Earbuds", Seconds: 10, Dose: 10},
+			},
+		},
+		{
+			name:    "split at midnight",
+			from:    beforeMidnight,
+			samples: []sample{{0, quiet}, {50 * time.Second, muted}},
+			want: []Record{
+				{Date: "2024-03-10", DeviceID: "hp", Name: "Headphones", Seconds: 30, Dose: 3},
+				{Date: "2024-03-11", DeviceID: "hp", Name: "Headphones", Seconds: 20, Dose: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewTracker("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var now time.Time
+			for _, s := range tt.samples {
+				now = tt.from.Add(s.at)
+				tracker.Update(now, s.state)
+			}
+
+			if history := tracker.History(now, 2); len(history) != len(tt.want) {
+				t.Fatalf("history = %+v, want %d record(s)", history, len(tt.want))
+			}
+			for _, want := range tt.want {
+				day, _ := time.ParseInLocation(dateLayout, want.Date, time.UTC)
+				got := tracker.Day(day, want.DeviceID)
+				if got.Name != want.Name ||
+					math.Abs(got.Seconds-want.Seconds) > 1e-9 ||
+					math.Abs(got.Dose-want.Dose) > 1e-6 {
+					t.Errorf("record %s/%s = %+v, want %+v", want.Date, want.DeviceID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTrackerMarkExceeded(t *testing.T) {
+	tracker, _ := NewTracker("")
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tracker.MarkExceeded(now, "hp")
+	if !tracker.Day(now, "hp").Exceeded {
+		t.Error("day is not marked as exceeded")
+	}
+	if tracker.Day(now.AddDate(0, 0, 1), "hp").Exceeded {
+		t.Error("next day is marked as exceeded")
+	}
+}
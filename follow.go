@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+// Режимы автопереключения
+const (
+	switchLock   = "lock"   // возвращать сохранённые устройства
+	switchFollow = "follow" // переходить на только что подключённое устройство
+)
+
+// followFlow - переключения режима следования для одного направления
+type followFlow struct {
+	deviceID string   // подключившееся устройство, на которое перешёл AutoSound
+	previous []string // устройства по умолчанию до подключений, недавние первыми
+}
+
+// followDevices делает устройством по умолчанию только что подключённое
+// устройство, а когда оно уходит, возвращает прежнее. Запрещённые
+// устройства и устройства с NoFollow не выбираются.
+func (a *App) followDevices(audioMgr audio.Backend, dataFlow audio.EDataFlow, kind string, blocked []settings.BlockRule) {
+	if a.presence == nil {
+		a.presence = audio.NewPresenceTracker()
+	}
+	arrived, left, err := a.presence.Update(audioMgr, dataFlow)
+	if err != nil {
+		log.Printf("Failed to list %s devices: %v", strings.ToLower(kind), err)
+		return
+	}
+
+	if a.followed == nil {
+		a.followed = make(map[audio.EDataFlow]*followFlow)
+	}
+	state := a.followed[dataFlow]
+	if state == nil {
+		state = &followFlow{}
+		a.followed[dataFlow] = state
+	}
+
+	state.previous = slices.DeleteFunc(state.previous, func(id string) bool {
+		return slices.Contains(left, id)
+	})
+	var target []string
+	if slices.Contains(left, state.deviceID) {
+		log.Printf("%s: followed device %s left, returning to the previous one", kind, state.deviceID)
+		state.deviceID = ""
+		target = slices.Clone(state.previous)
+	}
+
+	if newest := a.newestDevice(arrived, blocked); newest != "" {
+		// Прежнее устройство - выбранное при прошлой проверке: Windows
+		// могла уже сама переключиться на подключённое
+		if prior := a.lastDefault(dataFlow); prior != "" && prior != newest && !slices.Contains(left, prior) {
+			state.previous = moveToFront(state.previous, prior)
+		}
+		log.Printf("%s: %s connected, making it the default", kind, newest)
+		state.deviceID = newest
+		target = []string{newest}
+	}
+
+	if len(target) == 0 && len(blocked) == 0 {
+		return
+	}
+	if _, _, err := applyRoleDevices(audioMgr, dataFlow, target, nil, blocked, a.allowedDefaults[dataFlow]); err != nil {
+		log.Printf("Failed to switch %s device: %v", strings.ToLower(kind), err)
+	}
+	if len(blocked) > 0 {
+		a.rememberAllowedDefault(audioMgr, dataFlow, blocked)
+	}
+}
+
+// newestDevice возвращает последнее из подключившихся устройств, на которое
+// можно перейти; пустая строка - такого нет
+func (a *App) newestDevice(arrived []audio.AudioDevice, blocked []settings.BlockRule) string {
+	for _, dev := range slices.Backward(arrived) {
+		if deviceBlocked(blocked, dev.ID, dev.Name, audio.EMultimedia) {
+			continue
+		}
+		if a.deviceSettings(dev.ID).NoFollow {
+			log.Printf("Device %s connected, not following it", dev.ID)
+			continue
+		}
+		return dev.ID
+	}
+	return ""
+}
+
+// lastDefault возвращает устройство по умолчанию при прошлой проверке
+func (a *App) lastDefault(dataFlow audio.EDataFlow) string {
+	if dataFlow == audio.ECapture {
+		return a.defaultInputID
+	}
+	return a.defaultOutputID
+}
+
+// GetSwitchMode возвращает режим автопереключения: lock или follow
+func (a *App) GetSwitchMode() string {
+	if a.currentSettings().SwitchMode == switchFollow {
+		return switchFollow
+	}
+	return switchLock
+}
+
+// SetSwitchMode выбирает режим автопереключения
+func (a *App) SetSwitchMode(mode string) error {
+	if mode != switchLock && mode != switchFollow {
+		return fmt.Errorf("unknown switch mode %q", mode)
+	}
+	a.updateSettings(func(s *settings.Settings) {
+		s.SwitchMode = mode
+	})
+	a.clearLearnProposals()
+	a.wake()
+	return nil
+}
+
+// SetFollowDevice разрешает или запрещает переход на устройство
+// при его подключении в режиме follow
+func (a *App) SetFollowDevice(deviceID string, follow bool) {
+	a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+		dev.NoFollow = !follow
+	})
+}
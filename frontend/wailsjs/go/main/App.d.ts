@@ -2,16 +2,66 @@
 // This file is automatically generated. DO NOT EDIT
 import {main} from '../models';
 
+export function AdjustVolume(arg1:string,arg2:number):Promise<number>;
+
+export function AnswerLearnPrompt(arg1:string,arg2:boolean):Promise<void>;
+
 export function GetAutoSwitch():Promise<boolean>;
 
 export function GetAutostartEnabled():Promise<boolean>;
 
+export function GetBalance(arg1:string):Promise<number>;
+
+export function GetBlockedDevices(arg1:string):Promise<Array<main.BlockRuleInfo>>;
+
+export function GetChannelVolumes(arg1:string):Promise<Array<number>>;
+
+export function GetDeviceConflicts():Promise<Array<main.DeviceConflict>>;
+
+export function GetDeviceVolumeDB(arg1:string):Promise<number>;
+
+export function GetExposureBudget():Promise<main.ExposureBudget>;
+
+export function GetExposureStats():Promise<main.ExposureStats>;
+
 export function GetInputDevices():Promise<Array<main.AudioDeviceInfo>>;
 
+export function GetInputPriority():Promise<Array<string>>;
+
+export function GetLearnMode():Promise<main.LearnModeInfo>;
+
+export function GetLearnPrompts():Promise<Array<main.LearnPrompt>>;
+
+export function GetLockBalance():Promise<boolean>;
+
+export function GetLockMute():Promise<boolean>;
+
 export function GetLockVolume():Promise<boolean>;
 
 export function GetOutputDevices():Promise<Array<main.AudioDeviceInfo>>;
 
+export function GetOutputPriority():Promise<Array<string>>;
+
+export function GetPauseStatus():Promise<main.PauseStatus>;
+
+export function GetShowInactiveDevices():Promise<boolean>;
+
+export function GetSwitchMode():Promise<string>;
+
+export function GetVolumeCap(arg1:string):Promise<number>;
+
+export function GetVolumeCapStatus():Promise<main.VolumeCapStatus>;
+
+export function GetVolumeCurve(arg1:string):Promise<main.VolumeCurveInfo>;
+
+export function GetVolumeLimits(arg1:string):Promise<main.VolumeLimits>;
+
+export function GetVolumeRamp():Promise<main.VolumeRampInfo>;
+
+export function GetVolumeRange(arg1:string):Promise<main.VolumeRangeInfo>;
+
+export function GetVolumeStepInfo(arg1:string):Promise<main.VolumeStepInfo>;
+
 export function GetVolumes():Promise<main.VolumeInfo>;
 
 export function HasUnsavedChanges():Promise<boolean>;
@@ -22,26 +72,80 @@ export function MarkAutostartAsked():Promise<void>;
 
 export function MinimizeWindow():Promise<void>;
 
+export function PauseEnforcement(arg1:number):Promise<void>;
+
 export function Quit():Promise<void>;
 
 export function ResetChanges():Promise<void>;
 
+export function ResumeEnforcement():Promise<void>;
+
 export function SaveSettings():Promise<void>;
 
 export function SelectInputDevice(arg1:string):Promise<void>;
 
+export function SelectInputDeviceForRole(arg1:string,arg2:string):Promise<void>;
+
 export function SelectOutputDevice(arg1:string):Promise<void>;
 
+export function SelectOutputDeviceForRole(arg1:string,arg2:string):Promise<void>;
+
 export function SetAutoSwitch(arg1:boolean):Promise<void>;
 
 export function SetAutostartEnabled(arg1:boolean):Promise<void>;
 
+export function SetBalance(arg1:string,arg2:number):Promise<void>;
+
+export function SetBlockedDevices(arg1:string,arg2:Array<main.BlockRuleInfo>):Promise<void>;
+
+export function SetChannelVolumes(arg1:string,arg2:Array<number>):Promise<void>;
+
+export function SetDeviceVolumeDB(arg1:string,arg2:number):Promise<void>;
+
+export function SetExposureBudget(arg1:main.ExposureBudget):Promise<void>;
+
+export function SetFollowDevice(arg1:string,arg2:boolean):Promise<void>;
+
+export function SetInputPriority(arg1:Array<string>):Promise<void>;
+
 export function SetInputVolume(arg1:number):Promise<void>;
 
+export function SetLearnMode(arg1:main.LearnModeInfo):Promise<void>;
+
+export function SetLockBalance(arg1:boolean):Promise<void>;
+
+export function SetLockMute(arg1:boolean):Promise<void>;
+
 export function SetLockVolume(arg1:boolean):Promise<void>;
 
+export function SetOutputPriority(arg1:Array<string>):Promise<void>;
+
 export function SetOutputVolume(arg1:number):Promise<void>;
 
+export function SetShowInactiveDevices(arg1:boolean):Promise<void>;
+
+export function SetSwitchMode(arg1:string):Promise<void>;
+
+export function SetVolumeCap(arg1:string,arg2:number):Promise<void>;
+
+export function SetVolumeCurve(arg1:string,arg2:main.VolumeCurveInfo):Promise<void>;
+
+export function SetVolumeLimits(arg1:string,arg2:main.VolumeLimits):Promise<void>;
+
+export function SetVolumeRamp(arg1:main.VolumeRampInfo):Promise<void>;
+
 export function ShouldShowAutostartPrompt():Promise<boolean>;
 
 export function ShowWindow():Promise<void>;
+
+export function ToggleInputMute():Promise<boolean>;
+
+export function ToggleOutputMute():Promise<boolean>;
+
+export function VolumeDBToScalar(arg1:string,arg2:number):Promise<number>;
+
+export function VolumeScalarToDB(arg1:string,arg2:number):Promise<number>;
+
+export function VolumeStepDown(arg1:string):Promise<number>;
+
+export function VolumeStepUp(arg1:string):Promise<number>;
@@ -2,6 +2,14 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AdjustVolume(arg1,arg2) {
+  return window['go']['main']['App']['AdjustVolume'](arg1,arg2);
+}
+
+export function AnswerLearnPrompt(arg1,arg2) {
+  return window['go']['main']['App']['AnswerLearnPrompt'](arg1,arg2);
+}
+
 export function GetAutoSwitch() {
   return window['go']['main']['App']['GetAutoSwitch']();
 }
@@ -10,10 +18,58 @@ export function GetAutostartEnabled() {
   return window['go']['main']['App']['GetAutostartEnabled']();
 }
 
+export function GetBalance(arg1) {
+  return window['go']['main']['App']['GetBalance'](arg1);
+}
+
+export function GetBlockedDevices(arg1) {
+  return window['go']['main']['App']['GetBlockedDevices'](arg1);
+}
+
+export function GetChannelVolumes(arg1) {
+  return window['go']['main']['App']['GetChannelVolumes'](arg1);
+}
+
+export function GetDeviceConflicts() {
+  return window['go']['main']['App']['GetDeviceConflicts']();
+}
+
+export function GetDeviceVolumeDB(arg1) {
+  return window['go']['main']['App']['GetDeviceVolumeDB'](arg1);
+}
+
+export function GetExposureBudget() {
+  return window['go']['main']['App']['GetExposureBudget']();
+}
+
+export function GetExposureStats() {
+  return window['go']['main']['App']['GetExposureStats']();
+}
+
 export function GetInputDevices() {
   return window['go']['main']['App']['GetInputDevices']();
 }
 
+export function GetInputPriority() {
+  return window['go']['main']['App']['GetInputPriority']();
+}
+
+export function GetLearnMode() {
+  return window['go']['main']['App']['GetLearnMode']();
+}
+
+export function GetLearnPrompts() {
+  return window['go']['main']['App']['GetLearnPrompts']();
+}
+
+export function GetLockBalance() {
+  return window['go']['main']['App']['GetLockBalance']();
+}
+
+export function GetLockMute() {
+  return window['go']['main']['App']['GetLockMute']();
+}
+
 export function GetLockVolume() {
   return window['go']['main']['App']['GetLockVolume']();
 }
@@ -22,6 +78,50 @@ export function GetOutputDevices() {
   return window['go']['main']['App']['GetOutputDevices']();
 }
 
+export function GetOutputPriority() {
+  return window['go']['main']['App']['GetOutputPriority']();
+}
+
+export function GetPauseStatus() {
+  return window['go']['main']['App']['GetPauseStatus']();
+}
+
+export function GetShowInactiveDevices() {
+  return window['go']['main']['App']['GetShowInactiveDevices']();
+}
+
+export function GetSwitchMode() {
+  return window['go']['main']['App']['GetSwitchMode']();
+}
+
+export function GetVolumeCap(arg1) {
+  return window['go']['main']['App']['GetVolumeCap'](arg1);
+}
+
+export function GetVolumeCapStatus() {
+  return window['go']['main']['App']['GetVolumeCapStatus']();
+}
+
+export function GetVolumeCurve(arg1) {
+  return window['go']['main']['App']['GetVolumeCurve'](arg1);
+}
+
+export function GetVolumeLimits(arg1) {
+  return window['go']['main']['App']['GetVolumeLimits'](arg1);
+}
+
+export function GetVolumeRamp() {
+  return window['go']['main']['App']['GetVolumeRamp']();
+}
+
+export function GetVolumeRange(arg1) {
+  return window['go']['main']['App']['GetVolumeRange'](arg1);
+}
+
+export function GetVolumeStepInfo(arg1) {
+  return window['go']['main']['App']['GetVolumeStepInfo'](arg1);
+}
+
 export function GetVolumes() {
   return window['go']['main']['App']['GetVolumes']();
 }
@@ -42,6 +142,10 @@ export function MinimizeWindow() {
   return window['go']['main']['App']['MinimizeWindow']();
 }
 
+export function PauseEnforcement(arg1) {
+  return window['go']['main']['App']['PauseEnforcement'](arg1);
+}
+
 export function Quit() {
   return window['go']['main']['App']['Quit']();
 }
@@ -50,6 +154,10 @@ export function ResetChanges() {
   return window['go']['main']['App']['ResetChanges']();
 }
 
+export function ResumeEnforcement() {
+  return window['go']['main']['App']['ResumeEnforcement']();
+}
+
 export function SaveSettings() {
   return window['go']['main']['App']['SaveSettings']();
 }
@@ -58,10 +166,18 @@ export function SelectInputDevice(arg1) {
   return window['go']['main']['App']['SelectInputDevice'](arg1);
 }
 
+export function SelectInputDeviceForRole(arg1,arg2) {
+  return window['go']['main']['App']['SelectInputDeviceForRole'](arg1,arg2);
+}
+
 export function SelectOutputDevice(arg1) {
   return window['go']['main']['App']['SelectOutputDevice'](arg1);
 }
 
+export function SelectOutputDeviceForRole(arg1,arg2) {
+  return window['go']['main']['App']['SelectOutputDeviceForRole'](arg1,arg2);
+}
+
 export function SetAutoSwitch(arg1) {
   return window['go']['main']['App']['SetAutoSwitch'](arg1);
 }
@@ -70,18 +186,86 @@ export function SetAutostartEnabled(arg1) {
   return window['go']['main']['App']['SetAutostartEnabled'](arg1);
 }
 
+export function SetBalance(arg1,arg2) {
+  return window['go']['main']['App']['SetBalance'](arg1,arg2);
+}
+
+export function SetBlockedDevices(arg1,arg2) {
+  return window['go']['main']['App']['SetBlockedDevices'](arg1,arg2);
+}
+
+export function SetChannelVolumes(arg1,arg2) {
+  return window['go']['main']['App']['SetChannelVolumes'](arg1,arg2);
+}
+
+export function SetDeviceVolumeDB(arg1,arg2) {
+  return window['go']['main']['App']['SetDeviceVolumeDB'](arg1,arg2);
+}
+
+export function SetExposureBudget(arg1) {
+  return window['go']['main']['App']['SetExposureBudget'](arg1);
+}
+
+export function SetFollowDevice(arg1,arg2) {
+  return window['go']['main']['App']['SetFollowDevice'](arg1,arg2);
+}
+
+export function SetInputPriority(arg1) {
+  return window['go']['main']['App']['SetInputPriority'](arg1);
+}
+
 export function SetInputVolume(arg1) {
   return window['go']['main']['App']['SetInputVolume'](arg1);
 }
 
+export function SetLearnMode(arg1) {
+  return window['go']['main']['App']['SetLearnMode'](arg1);
+}
+
+export function SetLockBalance(arg1) {
+  return window['go']['main']['App']['SetLockBalance'](arg1);
+}
+
+export function SetLockMute(arg1) {
+  return window['go']['main']['App']['SetLockMute'](arg1);
+}
+
 export function SetLockVolume(arg1) {
   return window['go']['main']['App']['SetLockVolume'](arg1);
 }
 
+export function SetOutputPriority(arg1) {
+  return window['go']['main']['App']['SetOutputPriority'](arg1);
+}
+
 export function SetOutputVolume(arg1) {
   return window['go']['main']['App']['SetOutputVolume'](arg1);
 }
 
+export function SetShowInactiveDevices(arg1) {
+  return window['go']['main']['App']['SetShowInactiveDevices'](arg1);
+}
+
+export function SetSwitchMode(arg1) {
+  return window['go']['main']['App']['SetSwitchMode'](arg1);
+}
+
+export function SetVolumeCap(arg1,arg2) {
+  return window['go']['main']['App']['SetVolumeCap'](arg1,arg2);
+}
+
+export function SetVolumeCurve(arg1,arg2) {
+  return window['go']['main']['App']['SetVolumeCurve'](arg1,arg2);
+}
+
+export function SetVolumeLimits(arg1,arg2) {
+  return window['go']['main']['App']['SetVolumeLimits'](arg1,arg2);
+}
+
+export function SetVolumeRamp(arg1) {
+  return window['go']['main']['App']['SetVolumeRamp'](arg1);
+}
+
 export function ShouldShowAutostartPrompt() {
   return window['go']['main']['App']['ShouldShowAutostartPrompt']();
 }
@@ -89,3 +273,27 @@ export function ShouldShowAutostartPrompt() {
 export function ShowWindow() {
   return window['go']['main']['App']['ShowWindow']();
 }
+
+export function ToggleInputMute() {
+  return window['go']['main']['App']['ToggleInputMute']();
+}
+
+export function ToggleOutputMute() {
+  return window['go']['main']['App']['ToggleOutputMute']();
+}
+
+export function VolumeDBToScalar(arg1,arg2) {
+  return window['go']['main']['App']['VolumeDBToScalar'](arg1,arg2);
+}
+
+export function VolumeScalarToDB(arg1,arg2) {
+  return window['go']['main']['App']['VolumeScalarToDB'](arg1,arg2);
+}
+
+export function VolumeStepDown(arg1) {
+  return window['go']['main']['App']['VolumeStepDown'](arg1);
+}
+
+export function VolumeStepUp(arg1) {
+  return window['go']['main']['App']['VolumeStepUp'](arg1);
+}
@@ -6,6 +6,21 @@ export namespace main {
 	    isDefault: boolean;
 	    isChosen: boolean;
 	    isPending: boolean;
+	    state: string;
+	    priority: number;
+	    defaultRoles: string[];
+	    chosenRoles: string[];
+	    pendingRoles: string[];
+	    blockedRoles: string[];
+	    noFollow: boolean;
+	    formFactor: string;
+	    description: string;
+	    interfaceName: string;
+	    containerId: string;
+	    enumerator: string;
+	    jackSubType: string;
+	    iconPath: string;
+	    hint: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new AudioDeviceInfo(source);
@@ -18,12 +33,240 @@ export namespace main {
 	        this.isDefault = source["isDefault"];
 	        this.isChosen = source["isChosen"];
 	        this.isPending = source["isPending"];
+	        this.state = source["state"];
+	        this.priority = source["priority"];
+	        this.defaultRoles = source["defaultRoles"];
+	        this.chosenRoles = source["chosenRoles"];
+	        this.pendingRoles = source["pendingRoles"];
+	        this.blockedRoles = source["blockedRoles"];
+	        this.noFollow = source["noFollow"];
+	        this.formFactor = source["formFactor"];
+	        this.description = source["description"];
+	        this.interfaceName = source["interfaceName"];
+	        this.containerId = source["containerId"];
+	        this.enumerator = source["enumerator"];
+	        this.jackSubType = source["jackSubType"];
+	        this.iconPath = source["iconPath"];
+	        this.hint = source["hint"];
+	    }
+	}
+	export class BlockRuleInfo {
+	    deviceId: string;
+	    pattern: string;
+	    roles: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BlockRuleInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.deviceId = source["deviceId"];
+	        this.pattern = source["pattern"];
+	        this.roles = source["roles"];
+	    }
+	}
+	export class DeviceConflict {
+	    flow: string;
+	    winnerId: string;
+	    winnerName: string;
+	    restores: number;
+	    since: number;
+	    retryAt: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeviceConflict(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.flow = source["flow"];
+	        this.winnerId = source["winnerId"];
+	        this.winnerName = source["winnerName"];
+	        this.restores = source["restores"];
+	        this.since = source["since"];
+	        this.retryAt = source["retryAt"];
+	    }
+	}
+	export class ExposureBudget {
+	    minutes: number;
+	    action: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExposureBudget(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.minutes = source["minutes"];
+	        this.action = source["action"];
+	    }
+	}
+	export class ExposureDay {
+	    date: string;
+	    deviceId: string;
+	    name: string;
+	    minutes: number;
+	    doseMinutes: number;
+	    exceeded: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ExposureDay(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.date = source["date"];
+	        this.deviceId = source["deviceId"];
+	        this.name = source["name"];
+	        this.minutes = source["minutes"];
+	        this.doseMinutes = source["doseMinutes"];
+	        this.exceeded = source["exceeded"];
+	    }
+	}
+	export class ExposureStats {
+	    today: ExposureDay;
+	    listening: boolean;
+	    budgetMinutes: number;
+	    history: ExposureDay[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ExposureStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.today = this.convertValues(source["today"], ExposureDay);
+	        this.listening = source["listening"];
+	        this.budgetMinutes = source["budgetMinutes"];
+	        this.history = this.convertValues(source["history"], ExposureDay);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LearnModeInfo {
+	    enabled: boolean;
+	    graceSeconds: number;
+	    defaultAction: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LearnModeInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.graceSeconds = source["graceSeconds"];
+	        this.defaultAction = source["defaultAction"];
+	    }
+	}
+	export class LearnPrompt {
+	    flow: string;
+	    deviceId: string;
+	    deviceName: string;
+	    previousId: string;
+	    previousName: string;
+	    deadline: number;
+	    defaultAction: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LearnPrompt(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.flow = source["flow"];
+	        this.deviceId = source["deviceId"];
+	        this.deviceName = source["deviceName"];
+	        this.previousId = source["previousId"];
+	        this.previousName = source["previousName"];
+	        this.deadline = source["deadline"];
+	        this.defaultAction = source["defaultAction"];
+	    }
+	}
+	export class PauseStatus {
+	    paused: boolean;
+	    untilRestart: boolean;
+	    until: number;
+	    remaining: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PauseStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.paused = source["paused"];
+	        this.untilRestart = source["untilRestart"];
+	        this.until = source["until"];
+	        this.remaining = source["remaining"];
+	    }
+	}
+	export class VolumeCapStatus {
+	    interventions: number;
+	    deviceId: string;
+	    requested: number;
+	    cap: number;
+	    time: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeCapStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.interventions = source["interventions"];
+	        this.deviceId = source["deviceId"];
+	        this.requested = source["requested"];
+	        this.cap = source["cap"];
+	        this.time = source["time"];
+	    }
+	}
+	export class VolumeCurveInfo {
+	    kind: string;
+	    points?: number[][];
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeCurveInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.points = source["points"];
 	    }
 	}
 	export class VolumeInfo {
 	    outputVolume: number;
 	    inputVolume: number;
 	    lockVolume: boolean;
+	    outputMuted: boolean;
+	    inputMuted: boolean;
+	    lockMute: boolean;
+	    outputDeviceId: string;
+	    inputDeviceId: string;
+	    outputSaved?: number;
+	    inputSaved?: number;
+	    outputDb: number;
+	    inputDb: number;
+	    outputInterference: number;
+	    inputInterference: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new VolumeInfo(source);
@@ -34,6 +277,75 @@ export namespace main {
 	        this.outputVolume = source["outputVolume"];
 	        this.inputVolume = source["inputVolume"];
 	        this.lockVolume = source["lockVolume"];
+	        this.outputMuted = source["outputMuted"];
+	        this.inputMuted = source["inputMuted"];
+	        this.lockMute = source["lockMute"];
+	        this.outputDeviceId = source["outputDeviceId"];
+	        this.inputDeviceId = source["inputDeviceId"];
+	        this.outputSaved = source["outputSaved"];
+	        this.inputSaved = source["inputSaved"];
+	        this.outputDb = source["outputDb"];
+	        this.inputDb = source["inputDb"];
+	        this.outputInterference = source["outputInterference"];
+	        this.inputInterference = source["inputInterference"];
+	    }
+	}
+	export class VolumeLimits {
+	    min?: number;
+	    max?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.min = source["min"];
+	        this.max = source["max"];
+	    }
+	}
+	export class VolumeRampInfo {
+	    durationMs: number;
+	    curve: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeRampInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.durationMs = source["durationMs"];
+	        this.curve = source["curve"];
+	    }
+	}
+	export class VolumeRangeInfo {
+	    minDb: number;
+	    maxDb: number;
+	    stepDb: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeRangeInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.minDb = source["minDb"];
+	        this.maxDb = source["maxDb"];
+	        this.stepDb = source["stepDb"];
+	    }
+	}
+	export class VolumeStepInfo {
+	    step: number;
+	    count: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new VolumeStepInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.step = source["step"];
+	        this.count = source["count"];
 	    }
 	}
 
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"time"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+	"github.com/energye/systray"
+	wailsRuntime "github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// defaultLearnGrace - сколько новое устройство должно продержаться,
+	// прежде чем AutoSound спросит о нём
+	defaultLearnGrace = 10 * time.Second
+	// maxLearnGraceSeconds - самая долгая выдержка, которую можно задать
+	maxLearnGraceSeconds = 600
+	// learnAnswerTimeout - сколько ждать ответа до действия по умолчанию
+	learnAnswerTimeout = time.Minute
+	// learnPromptEvent - событие фронтенду о новом вопросе
+	learnPromptEvent = "learn-prompt"
+)
+
+// Ответы на вопрос о новом устройстве
+const (
+	learnRestore = "restore"
+	learnAdopt   = "adopt"
+)
+
+// learnProposal - устройство, которое выбрали в обход AutoSound
+type learnProposal struct {
+	deviceID   string
+	previousID string        // устройство, которое вернёт восстановление
+	roles      []audio.ERole // роли, отданные новому устройству
+	seen       time.Time     // с этого момента устройство держится
+	asked      time.Time     // когда задан вопрос; нулевое - идёт выдержка
+	action     string        // ответ; пусто - ответа ещё нет
+}
+
+// deadline возвращает следующий срок вопроса: конец выдержки или ожидания
+// ответа; false - ответ уже есть
+func (p *learnProposal) deadline(grace time.Duration) (time.Time, bool) {
+	switch {
+	case p.action != "":
+		return time.Time{}, false
+	case p.asked.IsZero():
+		return p.seen.Add(grace), true
+	}
+	return p.asked.Add(learnAnswerTimeout), true
+}
+
+// learnGrace возвращает выдержку перед вопросом
+func (a *App) learnGrace() time.Duration {
+	seconds := a.currentSettings().LearnGraceSeconds
+	if seconds <= 0 {
+		return defaultLearnGrace
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// learnDefaultAction возвращает действие, если на вопрос не ответили
+func (a *App) learnDefaultAction() string {
+	if a.currentSettings().LearnDefaultAction == learnAdopt {
+		return learnAdopt
+	}
+	return learnRestore
+}
+
+// learnExternalChoice решает, восстанавливать ли устройство направления
+// в режиме обучения. Устройство, выбранное в обход AutoSound (по роли
+// multimedia), держится выдержку, затем AutoSound спрашивает, оставить его
+// или вернуть прежнее. Возвращает true, пока восстанавливать не нужно.
+// last - главное устройство при прошлой проверке.
+func (a *App) learnExternalChoice(audioMgr audio.Backend, dataFlow audio.EDataFlow, kind string, last *string, priority []string, roles map[string]string, blocked []settings.BlockRule) bool {
+	if len(priority) == 0 && len(roles) == 0 {
+		return false
+	}
+	devices, err := loadAllowedDevices(audioMgr, dataFlow, blocked)
+	if err != nil {
+		return false
+	}
+	current := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+	expected := devices.choose(audio.EMultimedia, current, priority, roles, a.allowedDefaults[dataFlow])
+
+	a.learnMu.Lock()
+	proposal := a.learnProposals[dataFlow]
+	a.learnMu.Unlock()
+
+	// Сохранённое устройство вернули, или это наше же запасное устройство,
+	// которое сейчас уступит вернувшемуся главному. Запрещённое устройство
+	// не предлагается.
+	if expected == "" || current == "" || current == expected || current == *last ||
+		devices.blocks(current, audio.EMultimedia) {
+		if proposal != nil {
+			a.clearLearnProposal(dataFlow)
+		}
+		return false
+	}
+
+	now := time.Now()
+	if proposal == nil || proposal.deviceID != current {
+		var held []audio.ERole
+		for _, role := range audio.Roles {
+			if audioMgr.GetDefaultDeviceID(dataFlow, role) == current {
+				held = append(held, role)
+			}
+		}
+		log.Printf("%s device changed externally to %s, waiting before asking", kind, current)
+		a.learnMu.Lock()
+		if a.learnProposals == nil {
+			a.learnProposals = make(map[audio.EDataFlow]*learnProposal)
+		}
+		a.learnProposals[dataFlow] = &learnProposal{deviceID: current, previousID: expected, roles: held, seen: now}
+		a.learnMu.Unlock()
+		a.updateLearnTray(audioMgr, dataFlow)
+		return true
+	}
+
+	a.learnMu.Lock()
+	action := proposal.action
+	asked := false
+	switch {
+	case action != "":
+	case proposal.asked.IsZero() && now.Sub(proposal.seen) >= a.learnGrace():
+		proposal.asked = now
+		asked = true
+	case !proposal.asked.IsZero() && now.Sub(proposal.asked) >= learnAnswerTimeout:
+		action = a.learnDefaultAction()
+		log.Printf("%s: no answer about %s, applying default action %q", kind, current, action)
+	}
+	a.learnMu.Unlock()
+
+	if asked {
+		log.Printf("%s: asking whether to adopt %s", kind, current)
+		a.updateLearnTray(audioMgr, dataFlow)
+		if a.ctx != nil {
+			wailsRuntime.EventsEmit(a.ctx, learnPromptEvent)
+		}
+		return true
+	}
+	if action == "" {
+		return true
+	}
+
+	a.clearLearnProposal(dataFlow)
+	if action == learnAdopt {
+		a.adoptDevice(dataFlow, proposal)
+		log.Printf("%s: adopted %s as the saved device", kind, current)
+		*last = current
+		return true
+	}
+	log.Printf("%s: restoring %s instead of %s", kind, proposal.previousID, current)
+	return false
+}
+
+// adoptDevice делает выбранное в обход AutoSound устройство главным
+// в сохранённом выборе. Несохранённый выбор в окне не трогается.
+// Выбор меняют и привязки, поэтому изменение идёт под settingsMu.
+func (a *App) adoptDevice(dataFlow audio.EDataFlow, proposal *learnProposal) {
+	a.settingsMu.Lock()
+	priority, deviceID, roles := &a.settings.OutputPriority, &a.settings.OutputDeviceID, &a.settings.OutputRoleDevices
+	pendingPriority, pendingRoles := &a.pendingOutputPriority, &a.pendingOutputRoles
+	if dataFlow == audio.ECapture {
+		priority, deviceID, roles = &a.settings.InputPriority, &a.settings.InputDeviceID, &a.settings.InputRoleDevices
+		pendingPriority, pendingRoles = &a.pendingInputPriority, &a.pendingInputRoles
+	}
+	unsaved := !slices.Equal(*pendingPriority, *priority) || !maps.Equal(*pendingRoles, *roles)
+
+	*priority = moveToFront(*priority, proposal.deviceID)
+	*deviceID = firstOf(*priority)
+	// Роли, которые тоже переключили, больше не держат отдельное устройство
+	if len(*roles) > 0 {
+		*roles = cloneRoles(*roles)
+		for _, role := range proposal.roles {
+			delete(*roles, role.String())
+		}
+	}
+	if !unsaved {
+		*pendingPriority = slices.Clone(*priority)
+		*pendingRoles = cloneRoles(*roles)
+	}
+	a.settingsMu.Unlock()
+
+	a.saveSettings()
+}
+
+// clearLearnProposal снимает вопрос направления
+func (a *App) clearLearnProposal(dataFlow audio.EDataFlow) {
+	a.learnMu.Lock()
+	delete(a.learnProposals, dataFlow)
+	a.learnMu.Unlock()
+	a.updateLearnTray(nil, dataFlow)
+}
+
+// clearLearnProposals снимает все вопросы, например после смены настроек
+func (a *App) clearLearnProposals() {
+	for _, dataFlow := range dataFlows {
+		a.clearLearnProposal(dataFlow)
+	}
+}
+
+// learnDeadline возвращает ближайший срок среди вопросов
+func (a *App) learnDeadline() (time.Time, bool) {
+	a.learnMu.Lock()
+	defer a.learnMu.Unlock()
+
+	var next time.Time
+	found := false
+	for _, proposal := range a.learnProposals {
+		if deadline, ok := proposal.deadline(a.learnGrace()); ok && (!found || deadline.Before(next)) {
+			next, found = deadline, true
+		}
+	}
+	return next, found
+}
+
+// LearnPrompt - вопрос об устройстве, выбранном в обход AutoSound
+type LearnPrompt struct {
+	Flow         string `json:"flow"` // output или input
+	DeviceID     string `json:"deviceId"`
+	DeviceName   string `json:"deviceName"`
+	PreviousID   string `json:"previousId"`
+	PreviousName string `json:"previousName"`
+	// Unix-время в миллисекундах, когда применится действие по умолчанию
+	Deadline      int64  `json:"deadline"`
+	DefaultAction string `json:"defaultAction"` // adopt или restore
+}
+
+// GetLearnPrompts возвращает вопросы, на которые ждётся ответ
+func (a *App) GetLearnPrompts() []LearnPrompt {
+	var prompts []LearnPrompt
+	for _, dataFlow := range dataFlows {
+		prompt, ok := a.learnPrompt(dataFlow)
+		if !ok {
+			continue
+		}
+		prompt.DeviceName = a.deviceName(dataFlow, prompt.DeviceID)
+		prompt.PreviousName = a.deviceName(dataFlow, prompt.PreviousID)
+		prompts = append(prompts, prompt)
+	}
+	return prompts
+}
+
+// learnPrompt возвращает заданный вопрос направления без имён устройств
+func (a *App) learnPrompt(dataFlow audio.EDataFlow) (LearnPrompt, bool) {
+	a.learnMu.Lock()
+	defer a.learnMu.Unlock()
+
+	proposal := a.learnProposals[dataFlow]
+	if proposal == nil || proposal.asked.IsZero() || proposal.action != "" {
+		return LearnPrompt{}, false
+	}
+	return LearnPrompt{
+		Flow:          flowName(dataFlow),
+		DeviceID:      proposal.deviceID,
+		PreviousID:    proposal.previousID,
+		Deadline:      proposal.asked.Add(learnAnswerTimeout).UnixMilli(),
+		DefaultAction: a.learnDefaultAction(),
+	}, true
+}
+
+// AnswerLearnPrompt отвечает на вопрос: adopt - оставить новое устройство,
+// иначе вернуть прежнее. Ответ применяет горутина уведомлений.
+func (a *App) AnswerLearnPrompt(flow string, adopt bool) error {
+	dataFlow, ok := parseFlowName(flow)
+	if !ok {
+		return fmt.Errorf("unknown flow %q", flow)
+	}
+
+	a.learnMu.Lock()
+	proposal := a.learnProposals[dataFlow]
+	if proposal == nil || proposal.asked.IsZero() {
+		a.learnMu.Unlock()
+		return fmt.Errorf("no pending question for %s device", flow)
+	}
+	proposal.action = learnRestore
+	if adopt {
+		proposal.action = learnAdopt
+	}
+	a.learnMu.Unlock()
+
+	a.updateLearnTray(nil, dataFlow)
+	a.wake()
+	return nil
+}
+
+// LearnModeInfo - настройки режима обучения для фронтенда
+type LearnModeInfo struct {
+	Enabled       bool   `json:"enabled"`
+	GraceSeconds  int    `json:"graceSeconds"`
+	DefaultAction string `json:"defaultAction"` // adopt или restore
+}
+
+// GetLearnMode возвращает настройки режима обучения
+func (a *App) GetLearnMode() LearnModeInfo {
+	return LearnModeInfo{
+		Enabled:       a.currentSettings().LearnMode,
+		GraceSeconds:  int(a.learnGrace() / time.Second),
+		DefaultAction: a.learnDefaultAction(),
+	}
+}
+
+// SetLearnMode включает режим обучения: устройство, выбранное в обход
+// AutoSound, не возвращается сразу, а предлагается сохранить
+func (a *App) SetLearnMode(mode LearnModeInfo) error {
+	if mode.GraceSeconds < 0 || mode.GraceSeconds > maxLearnGraceSeconds {
+		return fmt.Errorf("learn grace %d s is out of range 0..%d", mode.GraceSeconds, maxLearnGraceSeconds)
+	}
+	if mode.DefaultAction != learnAdopt && mode.DefaultAction != learnRestore {
+		return fmt.Errorf("unknown learn action %q", mode.DefaultAction)
+	}
+
+	a.updateSettings(func(s *settings.Settings) {
+		s.LearnMode = mode.Enabled
+		s.LearnGraceSeconds = mode.GraceSeconds
+		s.LearnDefaultAction = mode.DefaultAction
+	})
+
+	if !mode.Enabled {
+		a.clearLearnProposals()
+	}
+	a.wake()
+	return nil
+}
+
+// flowName возвращает имя направления для фронтенда и лога
+func flowName(dataFlow audio.EDataFlow) string {
+	if dataFlow == audio.ECapture {
+		return "input"
+	}
+	return "output"
+}
+
+// parseFlowName разбирает имя, возвращённое flowName
+func parseFlowName(name string) (audio.EDataFlow, bool) {
+	switch name {
+	case "output":
+		return audio.ERender, true
+	case "input":
+		return audio.ECapture, true
+	}
+	return 0, false
+}
+
+// learnMenu - пункты трея для ответа на вопрос направления
+type learnMenu struct {
+	adopt, restore *systray.MenuItem
+}
+
+// Строки трея о вопросе для каждого направления
+var (
+	learnTrayKeys = map[audio.EDataFlow]string{
+		audio.ERender:  trayLearnOutput,
+		audio.ECapture: trayLearnInput,
+	}
+	learnTrayTexts = map[audio.EDataFlow]string{
+		audio.ERender:  "Выбрано другое устройство вывода: оставить его?",
+		audio.ECapture: "Выбран другой микрофон: оставить его?",
+	}
+)
+
+// addLearnMenu добавляет в меню трея скрытые пункты ответа на вопросы
+func (a *App) addLearnMenu() {
+	menus := make(map[audio.EDataFlow]learnMenu, len(dataFlows))
+	for _, dataFlow := range dataFlows {
+		flow := flowName(dataFlow)
+		menu := learnMenu{
+			adopt:   systray.AddMenuItem("", "Сохранить новое устройство"),
+			restore: systray.AddMenuItem("", "Вернуть сохранённое устройство"),
+		}
+		menu.adopt.Click(func() {
+			a.AnswerLearnPrompt(flow, true)
+		})
+		menu.restore.Click(func() {
+			a.AnswerLearnPrompt(flow, false)
+		})
+		menu.adopt.Hide()
+		menu.restore.Hide()
+		menus[dataFlow] = menu
+	}
+
+	a.trayMu.Lock()
+	a.trayLearn = menus
+	a.trayMu.Unlock()
+
+	for _, dataFlow := range dataFlows {
+		a.updateLearnTray(nil, dataFlow)
+	}
+}
+
+// updateLearnTray показывает вопрос направления в подсказке и меню трея.
+// Имена устройств берутся из audioMgr, а без него - из общего бэкенда.
+func (a *App) updateLearnTray(audioMgr audio.Backend, dataFlow audio.EDataFlow) {
+	prompt, ok := a.learnPrompt(dataFlow)
+	if !ok {
+		a.setTrayStatus(learnTrayKeys[dataFlow], "")
+	} else {
+		a.setTrayStatus(learnTrayKeys[dataFlow], learnTrayTexts[dataFlow])
+	}
+
+	a.trayMu.Lock()
+	defer a.trayMu.Unlock()
+	menu, exists := a.trayLearn[dataFlow]
+	if !exists {
+		return
+	}
+	if !ok {
+		menu.adopt.Hide()
+		menu.restore.Hide()
+		return
+	}
+
+	if audioMgr == nil {
+		audioMgr = a.audioManager
+	}
+	menu.adopt.SetTitle("Оставить «" + displayName(audioMgr, dataFlow, prompt.DeviceID) + "»")
+	menu.restore.SetTitle("Вернуть «" + displayName(audioMgr, dataFlow, prompt.PreviousID) + "»")
+	menu.adopt.Show()
+	menu.restore.Show()
+}
+
+// displayName возвращает имя устройства или его ID, если имя неизвестно
+func displayName(audioMgr audio.Backend, dataFlow audio.EDataFlow, deviceID string) string {
+	if audioMgr != nil {
+		if name := lookupDeviceName(audioMgr, dataFlow, deviceID); name != "" {
+			return name
+		}
+	}
+	return deviceID
+}
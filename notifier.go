@@ -0,0 +1,566 @@
+package main
+
+import (
+	"log"
+	"runtime"
+	"time"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+const (
+	// fallbackPollInterval - период опроса, если уведомления недоступны
+	fallbackPollInterval = 2 * time.Second
+	// devicePollInterval - резервная проверка на случай,
+	// если уведомление было потеряно
+	devicePollInterval = 30 * time.Second
+	// volumeTolerance - допустимое отклонение зафиксированной громкости:
+	// меньше шага слайдера (1%), но покрывает округление драйвера
+	volumeTolerance = 0.005
+	// volumeToleranceDB - то же для громкости, заданной в децибелах;
+	// сохраняется значение, прочитанное после записи, поэтому шаг
+	// устройства на сравнение не влияет
+	volumeToleranceDB = 0.01
+	// balanceTolerance - допустимое отклонение баланса (-1..1)
+	balanceTolerance = 0.01
+)
+
+// dataFlows - направления, которые обслуживает AutoSound
+var dataFlows = []audio.EDataFlow{audio.ERender, audio.ECapture}
+
+func (a *App) startDeviceNotifier() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	audioMgr, err := a.newBackend()
+	if err != nil {
+		log.Printf("Failed to create audio manager for notifier: %v", err)
+		return
+	}
+	defer audioMgr.Close()
+
+	// Подписываемся на уведомления; без них опрашиваем как раньше
+	pollInterval := devicePollInterval
+	events, err := audioMgr.WatchDevices()
+	if err != nil {
+		log.Printf("Failed to subscribe to device notifications, falling back to polling: %v", err)
+		pollInterval = fallbackPollInterval
+	}
+
+	watcher := audio.NewVolumeWatcher(audioMgr)
+	defer watcher.Close()
+	if err := watcher.Sync(); err != nil {
+		log.Printf("Failed to subscribe to volume notifications, falling back to polling: %v", err)
+		pollInterval = fallbackPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// Срабатывает к концу паузы, к срокам вопросов о новых устройствах
+	// и к повторной попытке после конфликта
+	wakeup := time.NewTimer(0)
+	defer wakeup.Stop()
+
+	// Сразу приводим состояние к сохранённому, не дожидаясь первого события
+	a.enforceAll(audioMgr, watcher)
+	defer a.saveExposure()
+	a.scheduleWakeup(wakeup)
+
+	for {
+		select {
+		case <-a.stopNotifier:
+			return
+		case event := <-events:
+			a.handleDeviceEvent(audioMgr, watcher, event, events)
+		case event := <-watcher.Events():
+			a.handleVolumeEvent(audioMgr, event)
+			// Замер после всех исправлений, включая собственные записи
+			if event.DataFlow == audio.ERender {
+				a.trackExposure(audioMgr)
+			}
+		case <-ticker.C:
+			a.enforceAll(audioMgr, watcher)
+		case <-a.wakeNotifier:
+			a.enforceAll(audioMgr, watcher)
+		case <-wakeup.C:
+			a.enforceAll(audioMgr, watcher)
+		}
+		a.scheduleWakeup(wakeup)
+	}
+}
+
+// wake просит горутину уведомлений проверить всё, не дожидаясь опроса
+func (a *App) wake() {
+	select {
+	case a.wakeNotifier <- struct{}{}:
+	default:
+	}
+}
+
+// scheduleWakeup заводит timer на ближайший срок: конец паузы,
+// шаг вопроса о новом устройстве или срок конфликта за устройство
+func (a *App) scheduleWakeup(timer *time.Timer) {
+	timer.Stop()
+	select {
+	case <-timer.C:
+	default:
+	}
+
+	deadline, ok := a.pauseDeadline()
+	earliest := func(next time.Time, nextOK bool) {
+		if nextOK && (!ok || next.Before(deadline)) {
+			deadline, ok = next, true
+		}
+	}
+	earliest(a.learnDeadline())
+	now := time.Now()
+	earliest(a.outputConflict.wakeup(now))
+	earliest(a.inputConflict.wakeup(now))
+	if ok {
+		timer.Reset(time.Until(deadline))
+	}
+}
+
+// enforceAll проверяет устройства и громкость целиком
+func (a *App) enforceAll(audioMgr audio.Backend, watcher *audio.VolumeWatcher) {
+	// На паузе устройства и фиксации не восстанавливаются
+	paused := a.enforcementPaused()
+	current := a.currentSettings()
+
+	// Громкость из старых настроек без устройства достаётся текущему
+	if current.HasLegacyVolume() {
+		a.bindLegacyVolume(audioMgr)
+	}
+
+	// Проверяем устройства (если включено автопереключение); без него
+	// только уводим роли с запрещённых устройств
+	if !paused {
+		if current.AutoSwitch {
+			a.enforceDevices(audioMgr)
+		} else {
+			a.enforceBlocklists(audioMgr)
+		}
+	}
+	// Подключения, пока режим следования не работал, новыми не считаются
+	if !current.AutoSwitch || paused || current.SwitchMode != switchFollow {
+		a.presence = nil
+	}
+
+	// Подписка на громкость следует за устройством по умолчанию
+	watcher.Sync()
+
+	// Новое устройство по умолчанию получает свою запомненную громкость
+	a.applySwitchedDevice(audioMgr, audio.ERender, &a.defaultOutputID)
+	a.applySwitchedDevice(audioMgr, audio.ECapture, &a.defaultInputID)
+
+	if !paused {
+		a.enforceLocks(audioMgr)
+	}
+
+	// Потолок громкости действует независимо от блокировок и паузы
+	a.enforceVolumeCaps(audioMgr)
+
+	// Замер нагрузки на наушники; опрос нужен и без событий громкости
+	a.trackExposure(audioMgr)
+}
+
+// enforceLocks возвращает зафиксированные громкость, баланс и звук
+func (a *App) enforceLocks(audioMgr audio.Backend) {
+	current := a.currentSettings()
+
+	// Проверяем громкость (если включена блокировка)
+	if current.LockVolume {
+		a.enforceVolumes(audioMgr)
+	}
+
+	// Проверяем баланс (если включена фиксация)
+	if current.LockBalance {
+		a.enforceBalance(audioMgr)
+	}
+
+	// Проверяем диапазоны громкости устройств
+	a.enforceVolumeLimits(audioMgr)
+
+	// Проверяем выключенный звук (если включена любая блокировка)
+	if current.LockVolume || current.LockMute {
+		a.enforceMute(audioMgr)
+	}
+}
+
+// handleDeviceEvent реагирует на уведомление об изменении устройств.
+// Одно переключение порождает событие на каждую роль, поэтому накопившиеся
+// события вычитываются и обрабатываются одной проверкой.
+func (a *App) handleDeviceEvent(audioMgr audio.Backend, watcher *audio.VolumeWatcher, event audio.DeviceEvent, events <-chan audio.DeviceEvent) {
+	if event.Type != audio.DefaultDeviceChanged {
+		log.Printf("Device %s: %s", event.Type, event.DeviceID)
+	}
+
+	for drained := false; !drained; {
+		select {
+		case <-events:
+		default:
+			drained = true
+		}
+	}
+
+	a.enforceAll(audioMgr, watcher)
+}
+
+// handleVolumeEvent сразу возвращает зафиксированную громкость и выключенный
+// звук. Собственные изменения AutoSound (эхо наших записей) игнорируются;
+// потолок громкости проверяется и для них, в том числе на паузе.
+// Вмешательством считается только изменение, которое AutoSound отменил.
+func (a *App) handleVolumeEvent(audioMgr audio.Backend, event audio.VolumeEvent) {
+	level, restored := a.restoreVolumeCap(audioMgr, event.DeviceID, event.Level)
+	if event.Self || a.enforcementPaused() {
+		return
+	}
+
+	if muted, ok := a.lockedMute(event.DeviceID); ok && event.Muted != muted {
+		log.Printf("Mute of %s changed externally, restoring...", event.DeviceID)
+		if audioMgr.SetDeviceMute(event.DeviceID, muted) == nil {
+			restored = true
+		}
+	}
+
+	if a.restoreBalance(audioMgr, event.DeviceID, level, event.Channels) {
+		restored = true
+	}
+
+	// Точная фиксация строже диапазона и проверяется первой
+	if locked, wrote := a.restoreLockedVolume(audioMgr, event.DeviceID, level); locked {
+		restored = restored || wrote
+	} else if limited := a.deviceSettings(event.DeviceID).LimitVolume(level); limited != level {
+		log.Printf("Volume of %s left its range (%.2f -> %.2f), restoring...", event.DeviceID, level, limited)
+		if a.rampVolume(audioMgr, event.DeviceID, limited) == nil {
+			restored = true
+		}
+	}
+
+	if restored {
+		count := a.countInterference(event.DataFlow)
+		log.Printf("External change of %s undone (interference #%d)", event.DeviceID, count)
+	}
+}
+
+// countInterference учитывает отменённое внешнее изменение громкости направления
+func (a *App) countInterference(dataFlow audio.EDataFlow) int64 {
+	switch dataFlow {
+	case audio.ERender:
+		return a.outputInterference.Add(1)
+	case audio.ECapture:
+		return a.inputInterference.Add(1)
+	}
+	return 0
+}
+
+// restoreLockedVolume возвращает зафиксированную громкость устройства,
+// если текущая (level) от неё отличается. Громкость, заданная в dB,
+// сравнивается в dB. Громкость 0 - такое же значение, как любое другое.
+// locked - у устройства есть зафиксированная громкость; restored -
+// громкость пришлось вернуть.
+func (a *App) restoreLockedVolume(audioMgr audio.Backend, deviceID string, level float32) (locked, restored bool) {
+	if !a.currentSettings().LockVolume {
+		return false, false
+	}
+
+	saved := a.deviceSettings(deviceID)
+	switch {
+	case saved.VolumeDB != nil:
+		current, err := audioMgr.GetDeviceVolumeDB(deviceID)
+		if err != nil {
+			return true, false
+		}
+		diff := current - *saved.VolumeDB
+		// Потолок важнее фиксации: громкость у потолка не поднимаем
+		if diff < -volumeToleranceDB && saved.VolumeCap != nil && level >= *saved.VolumeCap-volumeTolerance {
+			return true, false
+		}
+		if diff < -volumeToleranceDB || diff > volumeToleranceDB {
+			log.Printf("Volume of %s changed externally (%.2f dB -> %.2f dB), restoring...", deviceID, current, *saved.VolumeDB)
+			return true, a.rampVolumeDB(audioMgr, deviceID, *saved.VolumeDB) == nil
+		}
+		return true, false
+	case saved.Volume != nil:
+		want := saved.CapVolume(*saved.Volume)
+		diff := level - want
+		if diff < -volumeTolerance || diff > volumeTolerance {
+			log.Printf("Volume of %s changed externally (%.2f -> %.2f), restoring...", deviceID, level, want)
+			return true, a.rampVolume(audioMgr, deviceID, want) == nil
+		}
+		return true, false
+	}
+	return false, false
+}
+
+// lockedMute возвращает состояние звука, которое нужно удерживать.
+// Фиксация громкости удерживает и включённый, и выключенный звук;
+// LockMute только не даёт включить выключенный.
+func (a *App) lockedMute(deviceID string) (bool, bool) {
+	muted := a.deviceSettings(deviceID).Muted
+	if muted == nil {
+		return false, false
+	}
+	current := a.currentSettings()
+	if current.LockVolume {
+		return *muted, true
+	}
+	return true, current.LockMute && *muted
+}
+
+// enforceDevices восстанавливает сохранённые устройства по умолчанию
+// для каждой роли, переходя по списку приоритетов при отключении устройств,
+// и уводит роли с запрещённых устройств. В режиме follow вместо этого
+// следует за подключёнными устройствами.
+func (a *App) enforceDevices(audioMgr audio.Backend) {
+	if a.currentSettings().SwitchMode == switchFollow {
+		a.followDevices(audioMgr, audio.ERender, "Output", a.blockedDevices(audio.ERender))
+		a.followDevices(audioMgr, audio.ECapture, "Input", a.blockedDevices(audio.ECapture))
+		return
+	}
+	output := a.savedSelection(audio.ERender)
+	a.enforceFlowDevices(audioMgr, audio.ERender, "Output", &a.outputConflict, &a.activeOutputID,
+		output.priority, output.roles, a.blockedDevices(audio.ERender))
+	input := a.savedSelection(audio.ECapture)
+	a.enforceFlowDevices(audioMgr, audio.ECapture, "Input", &a.inputConflict, &a.activeInputID,
+		input.priority, input.roles, a.blockedDevices(audio.ECapture))
+}
+
+// enforceBlocklists уводит роли с запрещённых устройств, не возвращая
+// сохранённый выбор: так запреты действуют и без автопереключения
+func (a *App) enforceBlocklists(audioMgr audio.Backend) {
+	// Списка приоритетов нет, поэтому и отслеживать в нём нечего;
+	// у каждого направления своё устройство
+	var lastOutput, lastInput string
+	a.enforceFlowDevices(audioMgr, audio.ERender, "Output", &a.outputConflict, &lastOutput,
+		nil, nil, a.blockedDevices(audio.ERender))
+	a.enforceFlowDevices(audioMgr, audio.ECapture, "Input", &a.inputConflict, &lastInput,
+		nil, nil, a.blockedDevices(audio.ECapture))
+}
+
+// reportFallback пишет в лог переход на запасное устройство и возврат к главному
+func (a *App) reportFallback(kind string, last *string, resolved string, priority []string) {
+	if resolved == *last {
+		return
+	}
+
+	switch {
+	case resolved == "" && len(priority) > 0:
+		log.Printf("%s: no device from the priority list is connected", kind)
+	case resolved == firstOf(priority) && *last != "":
+		log.Printf("%s: preferred device %s is back, promoting it", kind, resolved)
+	case resolved != firstOf(priority):
+		log.Printf("%s: preferred device unavailable, falling back to %s", kind, resolved)
+	}
+	*last = resolved
+}
+
+// applyRoleDevices делает устройством по умолчанию первое подключённое
+// и не запрещённое устройство списка приоритетов. Роль с отдельным
+// устройством получает его, если оно подключено. Роль, которую Windows
+// отдала запрещённому устройству, уходит на fallback или на первое
+// разрешённое. Возвращает выбранное из списка устройство и роли,
+// которые пришлось переключить.
+func applyRoleDevices(audioMgr audio.Backend, dataFlow audio.EDataFlow, priority []string, roles map[string]string, blocked []settings.BlockRule, fallback string) (string, []audio.ERole, error) {
+	if len(priority) == 0 && len(roles) == 0 && len(blocked) == 0 {
+		return "", nil, nil
+	}
+
+	devices, err := loadAllowedDevices(audioMgr, dataFlow, blocked)
+	if err != nil {
+		return "", nil, err
+	}
+	mainID := devices.first(priority, audio.EMultimedia)
+
+	var switched []audio.ERole
+	var firstErr error
+	for _, role := range audio.Roles {
+		current := audioMgr.GetDefaultDeviceID(dataFlow, role)
+		deviceID := devices.choose(role, current, priority, roles, fallback)
+		if deviceID == "" || current == deviceID {
+			continue
+		}
+		if err := audioMgr.SetDefaultDeviceForRole(deviceID, role); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		switched = append(switched, role)
+	}
+
+	return mainID, switched, firstErr
+}
+
+// bindLegacyVolume переносит общую громкость из старых настроек, где не
+// было сохранённого устройства, на устройства по умолчанию
+func (a *App) bindLegacyVolume(audioMgr audio.Backend) {
+	outputID := audioMgr.GetDefaultDeviceID(audio.ERender, audio.EMultimedia)
+	inputID := audioMgr.GetDefaultDeviceID(audio.ECapture, audio.EMultimedia)
+
+	a.settingsMu.Lock()
+	changed := a.settings.BindLegacyVolume(outputID, inputID)
+	a.settingsMu.Unlock()
+
+	if changed {
+		log.Printf("Legacy volume bound to default devices %q/%q", outputID, inputID)
+		a.saveSettings()
+	}
+}
+
+// applySwitchedDevice применяет запомненную громкость и звук, когда
+// устройство по умолчанию сменилось. last - устройство при прошлой проверке.
+func (a *App) applySwitchedDevice(audioMgr audio.Backend, dataFlow audio.EDataFlow, last *string) {
+	deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+	if deviceID == *last {
+		return
+	}
+	previous := *last
+	*last = deviceID
+	// При запуске устройство не менялось: громкость приводит блокировка.
+	// На паузе устройство только запоминается, громкость не трогается.
+	if previous == "" || deviceID == "" || a.enforcementPaused() {
+		return
+	}
+
+	saved := a.deviceSettings(deviceID)
+	if saved.VolumeDB != nil {
+		log.Printf("Default device changed to %s, applying its volume %.2f dB", deviceID, *saved.VolumeDB)
+		a.rampVolumeDB(audioMgr, deviceID, *saved.VolumeDB)
+	} else if saved.Volume != nil {
+		log.Printf("Default device changed to %s, applying its volume %.2f", deviceID, *saved.Volume)
+		a.rampVolume(audioMgr, deviceID, *saved.Volume)
+	} else if a.currentSettings().LockVolume {
+		// Устройство ещё не встречалось: фиксируем его текущую громкость
+		if level, err := audioMgr.GetDeviceVolume(deviceID); err == nil {
+			a.updateDevice(deviceID, func(dev *settings.DeviceSettings) {
+				dev.Volume = &level
+			})
+		}
+	}
+	if saved.Muted != nil {
+		audioMgr.SetDeviceMute(deviceID, *saved.Muted)
+	}
+}
+
+// enforceVolumes восстанавливает зафиксированную громкость опросом;
+// используется при смене устройства и как резерв для уведомлений
+func (a *App) enforceVolumes(audioMgr audio.Backend) {
+	for _, dataFlow := range dataFlows {
+		deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		if deviceID == "" {
+			continue
+		}
+		if level, err := audioMgr.GetDeviceVolume(deviceID); err == nil {
+			a.restoreLockedVolume(audioMgr, deviceID, level)
+		}
+	}
+}
+
+// restoreBalance возвращает запомненный баланс устройства, не меняя
+// общую громкость level. channels - текущие уровни каналов.
+func (a *App) restoreBalance(audioMgr audio.Backend, deviceID string, level float32, channels []float32) bool {
+	if !a.currentSettings().LockBalance || level == 0 {
+		return false
+	}
+	saved := a.deviceSettings(deviceID).Channels
+	if len(saved) < 2 || len(saved) != len(channels) {
+		return false
+	}
+
+	want := audio.Balance(saved)
+	current := audio.Balance(channels)
+	if diff := current - want; diff >= -balanceTolerance && diff <= balanceTolerance {
+		return false
+	}
+	log.Printf("Balance of %s changed externally (%.2f -> %.2f), restoring...", deviceID, current, want)
+	return audioMgr.SetChannelVolumes(deviceID, audio.ChannelsForBalance(level, want, len(channels))) == nil
+}
+
+// enforceBalance восстанавливает баланс устройств по умолчанию опросом
+func (a *App) enforceBalance(audioMgr audio.Backend) {
+	for _, dataFlow := range dataFlows {
+		deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		if deviceID == "" {
+			continue
+		}
+		level, err := audioMgr.GetDeviceVolume(deviceID)
+		if err != nil {
+			continue
+		}
+		if channels, err := audioMgr.GetChannelVolumes(deviceID); err == nil {
+			a.restoreBalance(audioMgr, deviceID, level, channels)
+		}
+	}
+}
+
+// enforceVolumeLimits возвращает громкость устройств по умолчанию в их
+// диапазон; устройства с точной фиксацией проверяет enforceVolumes
+func (a *App) enforceVolumeLimits(audioMgr audio.Backend) {
+	for _, dataFlow := range dataFlows {
+		deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		saved := a.deviceSettings(deviceID)
+		if deviceID == "" || !saved.HasVolumeLimits() || (a.currentSettings().LockVolume && saved.HasLockedVolume()) {
+			continue
+		}
+		current, err := audioMgr.GetDeviceVolume(deviceID)
+		if err != nil {
+			continue
+		}
+		if limited := saved.LimitVolume(current); limited != current {
+			log.Printf("Volume of %s left its range (%.2f -> %.2f), restoring...", deviceID, current, limited)
+			a.rampVolume(audioMgr, deviceID, limited)
+		}
+	}
+}
+
+// restoreVolumeCap сразу опускает громкость устройства до его потолка,
+// минуя плавный переход, и возвращает громкость после проверки;
+// true - громкость пришлось опустить
+func (a *App) restoreVolumeCap(audioMgr audio.Backend, deviceID string, level float32) (float32, bool) {
+	saved := a.deviceSettings(deviceID)
+	capped := saved.CapVolume(level)
+	if level-capped <= volumeTolerance {
+		return level, false
+	}
+	log.Printf("Volume of %s is above its cap (%.2f -> %.2f), lowering...", deviceID, level, capped)
+	a.cancelRamp(deviceID)
+	if err := audioMgr.SetDeviceVolume(deviceID, capped); err != nil {
+		return level, false
+	}
+	a.recordCapIntervention(deviceID, level, capped)
+	return capped, true
+}
+
+// enforceVolumeCaps проверяет потолок громкости устройств по умолчанию
+// опросом - резерв для уведомлений
+func (a *App) enforceVolumeCaps(audioMgr audio.Backend) {
+	for _, dataFlow := range dataFlows {
+		deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		if deviceID == "" || a.deviceSettings(deviceID).VolumeCap == nil {
+			continue
+		}
+		if level, err := audioMgr.GetDeviceVolume(deviceID); err == nil {
+			a.restoreVolumeCap(audioMgr, deviceID, level)
+		}
+	}
+}
+
+// enforceMute восстанавливает зафиксированное состояние звука устройств
+// по умолчанию; используется при смене устройства и как резерв для уведомлений
+func (a *App) enforceMute(audioMgr audio.Backend) {
+	for _, dataFlow := range dataFlows {
+		deviceID := audioMgr.GetDefaultDeviceID(dataFlow, audio.EMultimedia)
+		muted, ok := a.lockedMute(deviceID)
+		if deviceID == "" || !ok {
+			continue
+		}
+		current, err := audioMgr.GetDeviceMute(deviceID)
+		if err == nil && current != muted {
+			log.Printf("Mute of %s changed externally, restoring...", deviceID)
+			audioMgr.SetDeviceMute(deviceID, muted)
+		}
+	}
+}
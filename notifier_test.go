@@ -0,0 +1,254 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"AutoSoundWindows/audio"
+	"AutoSoundWindows/settings"
+)
+
+// readyBackend замечает, что горутина уведомлений прошла первую проверку
+// и ждёт событий: канал громкости она запрашивает в каждом select
+type readyBackend struct {
+	*audio.FakeBackend
+	once  sync.Once
+	ready chan struct{}
+}
+
+func (b *readyBackend) VolumeEvents() <-chan audio.VolumeEvent {
+	b.once.Do(func() { close(b.ready) })
+	return b.FakeBackend.VolumeEvents()
+}
+
+// startTestNotifier запускает горутину уведомлений, дожидается первой
+// проверки и останавливает горутину в конце теста
+func startTestNotifier(t *testing.T, a *App, fake *audio.FakeBackend) {
+	t.Helper()
+
+	backend := &readyBackend{FakeBackend: fake, ready: make(chan struct{})}
+	a.newBackend = func() (audio.Backend, error) { return backend, nil }
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.startDeviceNotifier()
+	}()
+	t.Cleanup(func() {
+		close(a.stopNotifier)
+		<-done
+	})
+
+	select {
+	case <-backend.ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("notifier did not start")
+	}
+}
+
+// waitFor ждёт, пока условие выполнится
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func volumeIs(fake *audio.FakeBackend, deviceID string, want float32) func() bool {
+	return func() bool {
+		level, err := fake.GetDeviceVolume(deviceID)
+		return err == nil && level == want
+	}
+}
+
+func TestNotifierRestoresDefaultDevice(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	a.SelectOutputDevice("headphones")
+	if err := a.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+	startTestNotifier(t, a, fake)
+
+	// Другая программа переключает вывод на колонки
+	if err := fake.SetDefaultDevice("speakers"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "headphones to be restored for every role", func() bool {
+		for _, role := range audio.Roles {
+			if fake.GetDefaultDeviceID(audio.ERender, role) != "headphones" {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestNotifierFallsBackAndPromotesPreferredDevice(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	fake.AddDevice("usb", "USB Headset", audio.ERender, 0.5)
+	a.SetOutputPriority([]string{"usb", "headphones"})
+	if err := a.SaveSettings(); err != nil {
+		t.Fatal(err)
+	}
+	startTestNotifier(t, a, fake)
+
+	// Windows отдаёт роли первому устройству, AutoSound - следующему в списке
+	fake.RemoveDevice("usb")
+	waitFor(t, "fallback to headphones", func() bool {
+		return fake.GetCurrentDefaultOutputID() == "headphones"
+	})
+
+	fake.AddDevice("usb", "USB Headset", audio.ERender, 0.5)
+	waitFor(t, "usb to be promoted back", func() bool {
+		return fake.GetCurrentDefaultOutputID() == "usb"
+	})
+}
+
+func TestNotifierRestoresLockedVolume(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	a.SetLockVolume(true)
+	startTestNotifier(t, a, fake)
+
+	if err := fake.SetExternalVolume("speakers", 0.875); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "locked volume to be restored", volumeIs(fake, "speakers", 0.5))
+	waitFor(t, "interference to be counted", func() bool {
+		return a.outputInterference.Load() == 1
+	})
+
+	if err := fake.SetExternalMute("speakers", true); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "mute to be restored", func() bool {
+		muted, err := fake.GetDeviceMute("speakers")
+		return err == nil && !muted
+	})
+}
+
+func TestNotifierAppliesRememberedVolumeOnSwitch(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	a.updateDevice("headphones", func(dev *settings.DeviceSettings) {
+		dev.Volume = ptrTo(float32(0.125))
+	})
+	startTestNotifier(t, a, fake)
+
+	if err := fake.SetDefaultDevice("headphones"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "remembered volume to be applied", volumeIs(fake, "headphones", 0.125))
+}
+
+func TestApplySwitchedDeviceWhilePaused(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	a.updateDevice("headphones", func(dev *settings.DeviceSettings) {
+		dev.Volume = ptrTo(float32(0.125))
+	})
+	last := "speakers"
+	if err := a.PauseEnforcement(15); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.SetDefaultDevice("headphones")
+	a.applySwitchedDevice(fake, audio.ERender, &last)
+	if level, _ := fake.GetDeviceVolume("headphones"); level != 0.25 {
+		t.Errorf("volume while paused = %v, want untouched 0.25", level)
+	}
+	if last != "headphones" {
+		t.Errorf("last device = %q, want headphones", last)
+	}
+
+	// После паузы прежнее переключение уже не применяется
+	a.ResumeEnforcement()
+	a.applySwitchedDevice(fake, audio.ERender, &last)
+	if level, _ := fake.GetDeviceVolume("headphones"); level != 0.25 {
+		t.Errorf("volume after resume = %v, want untouched 0.25", level)
+	}
+}
+
+func TestBindLegacyVolumeToDefaultDevice(t *testing.T) {
+	fake := audio.NewFakeBackend()
+	fake.AddDevice("speakers", "Speakers", audio.ERender, 0.5)
+	dir := t.TempDir()
+	legacy := `{"version": 1, "lock_volume": true, "output_volume": 0.125}`
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+	a := newTestAppInDir(t, fake, dir)
+
+	a.bindLegacyVolume(fake)
+	if saved := a.deviceSettings("speakers").Volume; saved == nil || *saved != 0.125 {
+		t.Fatalf("speakers volume = %v, want legacy 0.125", saved)
+	}
+	if reloaded := newTestAppInDir(t, fake, dir).currentSettings(); reloaded.HasLegacyVolume() {
+		t.Error("legacy volume is still saved after binding")
+	}
+}
+
+func TestHandleVolumeEventCountsOnlyRestoredChanges(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	external := func(level float32) audio.VolumeEvent {
+		fake.SetExternalVolume("speakers", level)
+		return audio.VolumeEvent{DeviceID: "speakers", DataFlow: audio.ERender, Level: level, Channels: []float32{level, level}}
+	}
+
+	// Без фиксации громкость меняет сам пользователь
+	a.handleVolumeEvent(fake, external(0.75))
+	if got := a.outputInterference.Load(); got != 0 {
+		t.Errorf("interference without locks = %d, want 0", got)
+	}
+
+	// Собственные записи не считаются, даже если они расходятся с фиксацией
+	a.SetLockVolume(true)
+	fake.SetDeviceVolume("speakers", 0.25)
+	a.handleVolumeEvent(fake, audio.VolumeEvent{
+		DeviceID: "speakers", DataFlow: audio.ERender, Level: 0.25,
+		EventContext: *audio.EventContext, Self: true,
+	})
+	if got := a.outputInterference.Load(); got != 0 {
+		t.Errorf("interference after own write = %d, want 0", got)
+	}
+
+	a.handleVolumeEvent(fake, external(1))
+	if got := a.outputInterference.Load(); got != 1 {
+		t.Errorf("interference after restored change = %d, want 1", got)
+	}
+	if level, _ := fake.GetDeviceVolume("speakers"); level != 0.75 {
+		t.Errorf("volume after restore = %v, want locked 0.75", level)
+	}
+
+	// Изменение в пределах допуска восстанавливать не нужно
+	a.handleVolumeEvent(fake, external(0.75))
+	if got := a.outputInterference.Load(); got != 1 {
+		t.Errorf("interference after no-op change = %d, want 1", got)
+	}
+}
+
+func TestNotifierLeavesBlockedDeviceWithoutAutoSwitch(t *testing.T) {
+	a, fake, _ := newTestApp(t)
+	a.SetAutoSwitch(false)
+	startTestNotifier(t, a, fake)
+
+	if err := a.SetBlockedDevices("output", []BlockRuleInfo{{Pattern: "speak*"}}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "blocked speakers to be replaced", func() bool {
+		return fake.GetCurrentDefaultOutputID() == "headphones"
+	})
+
+	if err := a.SetBlockedDevices("output", []BlockRuleInfo{{Pattern: `speak\`}}); err == nil {
+		t.Error("malformed pattern is accepted")
+	}
+	rules, err := a.GetBlockedDevices("output")
+	if err != nil || len(rules) != 1 || rules[0].Pattern != "speak*" {
+		t.Errorf("blocklist after rejected update = %v, %v", rules, err)
+	}
+}
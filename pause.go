@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/energye/systray"
+)
+
+// maxPauseMinutes - самая долгая пауза со сроком
+const maxPauseMinutes = 24 * 60
+
+// Варианты паузы в меню трея; 0 минут - до перезапуска
+var pausePresets = []struct {
+	title   string
+	minutes int
+}{
+	{"15 минут", 15},
+	{"1 час", 60},
+	{"До перезапуска", 0},
+}
+
+// PauseStatus - состояние паузы восстановления для фронтенда
+type PauseStatus struct {
+	Paused       bool  `json:"paused"`
+	UntilRestart bool  `json:"untilRestart"`
+	Until        int64 `json:"until"`     // Unix-время в миллисекундах; 0 - до перезапуска
+	Remaining    int64 `json:"remaining"` // секунд до возобновления
+}
+
+// PauseEnforcement приостанавливает восстановление устройств и фиксаций
+// на minutes минут; 0 - до перезапуска программы. Срок паузы сохраняется
+// в настройках и переживает перезапуск.
+func (a *App) PauseEnforcement(minutes int) error {
+	if minutes < 0 || minutes > maxPauseMinutes {
+		return fmt.Errorf("pause of %d min is out of range 0..%d", minutes, maxPauseMinutes)
+	}
+
+	a.pauseMu.Lock()
+	if minutes == 0 {
+		a.pausedUntilRestart = true
+		a.setPausedUntil(nil)
+		log.Printf("Enforcement paused until restart")
+	} else {
+		until := time.Now().Add(time.Duration(minutes) * time.Minute)
+		a.pausedUntilRestart = false
+		a.setPausedUntil(&until)
+		log.Printf("Enforcement paused until %s", until.Format(time.TimeOnly))
+	}
+	a.pauseMu.Unlock()
+
+	a.saveSettings()
+	a.pauseUpdated()
+	return nil
+}
+
+// ResumeEnforcement досрочно снимает паузу; устройства и фиксации
+// восстанавливаются сразу
+func (a *App) ResumeEnforcement() {
+	a.pauseMu.Lock()
+	wasPaused := a.pausedUntilRestart || a.currentSettings().PausedUntil != nil
+	a.pausedUntilRestart = false
+	a.setPausedUntil(nil)
+	a.pauseMu.Unlock()
+
+	if !wasPaused {
+		return
+	}
+	log.Printf("Enforcement resumed")
+	a.saveSettings()
+	a.pauseUpdated()
+}
+
+// GetPauseStatus возвращает состояние паузы и оставшееся время
+func (a *App) GetPauseStatus() PauseStatus {
+	a.enforcementPaused()
+
+	a.pauseMu.Lock()
+	defer a.pauseMu.Unlock()
+
+	if a.pausedUntilRestart {
+		return PauseStatus{Paused: true, UntilRestart: true}
+	}
+	pausedUntil := a.currentSettings().PausedUntil
+	if pausedUntil == nil {
+		return PauseStatus{}
+	}
+	until := *pausedUntil
+	return PauseStatus{
+		Paused:    true,
+		Until:     until.UnixMilli(),
+		Remaining: int64(time.Until(until).Round(time.Second) / time.Second),
+	}
+}
+
+// enforcementPaused сообщает, действует ли пауза. Истёкшая пауза
+// снимается здесь же.
+func (a *App) enforcementPaused() bool {
+	a.pauseMu.Lock()
+	if a.pausedUntilRestart {
+		a.pauseMu.Unlock()
+		return true
+	}
+	until := a.currentSettings().PausedUntil
+	if until == nil {
+		a.pauseMu.Unlock()
+		return false
+	}
+	if time.Now().Before(*until) {
+		a.pauseMu.Unlock()
+		return true
+	}
+	a.setPausedUntil(nil)
+	a.pauseMu.Unlock()
+
+	log.Printf("Enforcement pause expired, resuming")
+	a.saveSettings()
+	a.updatePauseStatus()
+	return false
+}
+
+// pauseDeadline возвращает срок паузы; false - паузы со сроком нет
+func (a *App) pauseDeadline() (time.Time, bool) {
+	a.pauseMu.Lock()
+	defer a.pauseMu.Unlock()
+	until := a.currentSettings().PausedUntil
+	if a.pausedUntilRestart || until == nil {
+		return time.Time{}, false
+	}
+	return *until, true
+}
+
+// setPausedUntil задаёт срок паузы в настройках. Вызывается под pauseMu;
+// settingsMu нужен, потому что настройки в это время может сохранять
+// другая горутина.
+func (a *App) setPausedUntil(until *time.Time) {
+	a.settingsMu.Lock()
+	a.settings.PausedUntil = until
+	a.settingsMu.Unlock()
+}
+
+// pauseUpdated обновляет трей и будит горутину уведомлений, чтобы она
+// перезапустила таймер и сразу применила или отпустила фиксации
+func (a *App) pauseUpdated() {
+	a.updatePauseStatus()
+	a.wake()
+}
+
+// updatePauseStatus показывает паузу в подсказке и меню трея
+func (a *App) updatePauseStatus() {
+	status := a.GetPauseStatus()
+	switch {
+	case !status.Paused:
+		a.setTrayStatus(trayPause, "")
+	case status.UntilRestart:
+		a.setTrayStatus(trayPause, "Восстановление приостановлено до перезапуска")
+	default:
+		a.setTrayStatus(trayPause, "Восстановление приостановлено до "+
+			time.UnixMilli(status.Until).Format("15:04"))
+	}
+
+	a.trayMu.Lock()
+	defer a.trayMu.Unlock()
+	if a.trayResume == nil {
+		return
+	}
+	if status.Paused {
+		a.trayResume.Enable()
+	} else {
+		a.trayResume.Disable()
+	}
+}
+
+// addPauseMenu добавляет в меню трея подменю паузы
+func (a *App) addPauseMenu() {
+	mPause := systray.AddMenuItem("Пауза", "Приостановить восстановление устройств и громкости")
+	for _, preset := range pausePresets {
+		item := mPause.AddSubMenuItem(preset.title, "")
+		minutes := preset.minutes
+		item.Click(func() {
+			a.PauseEnforcement(minutes)
+		})
+	}
+
+	mResume := mPause.AddSubMenuItem("Возобновить", "Снять паузу")
+	mResume.Click(func() {
+		a.ResumeEnforcement()
+	})
+
+	a.trayMu.Lock()
+	a.trayResume = mResume
+	a.trayMu.Unlock()
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package settings
+
+import "errors"
+
+// IsAutostartEnabled вне Windows автозапуск не поддерживается
+func IsAutostartEnabled() bool {
+	return false
+}
+
+// SetAutostart вне Windows автозапуск не поддерживается
+func SetAutostart(enabled bool) error {
+	return errors.New("autostart is not supported on this platform")
+}
@@ -0,0 +1,50 @@
+package settings
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const (
+	registryKey   = `Software\Microsoft\Windows\CurrentVersion\Run`
+	registryValue = "AutoSound"
+)
+
+// IsAutostartEnabled проверяет включен ли автозапуск в реестре
+func IsAutostartEnabled() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+
+	_, _, err = key.GetStringValue(registryValue)
+	return err == nil
+}
+
+// SetAutostart включает или выключает автозапуск
+func SetAutostart(enabled bool) error {
+	if enabled {
+		exePath, err := os.Executable()
+		if err != nil {
+			return err
+		}
+
+		key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.SET_VALUE)
+		if err != nil {
+			return err
+		}
+		defer key.Close()
+
+		return key.SetStringValue(registryValue, `"`+exePath+`"`)
+	} else {
+		key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.SET_VALUE)
+		if err != nil {
+			return err
+		}
+		defer key.Close()
+
+		return key.DeleteValue(registryValue)
+	}
+}
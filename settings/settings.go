@@ -2,26 +2,260 @@ package settings
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
-
-	"golang.org/x/sys/windows/registry"
+	"slices"
+	"strings"
+	"time"
 )
 
-const (
-	registryKey   = `Software\Microsoft\Windows\CurrentVersion\Run`
-	registryValue = "AutoSound"
-)
+// CurrentVersion - версия формата файла настроек
+const CurrentVersion = 2
 
 // Settings хранит настройки приложения
 type Settings struct {
-	OutputDeviceID string  `json:"output_device_id"`
-	InputDeviceID  string  `json:"input_device_id"`
-	OutputVolume   float32 `json:"output_volume"`
-	InputVolume    float32 `json:"input_volume"`
-	LockVolume     bool    `json:"lock_volume"`
-	AutoSwitch     bool    `json:"auto_switch"`
-	AutostartAsked bool    `json:"autostart_asked"`
+	// Версия формата; 0 - файл, сохранённый до появления версий
+	Version int `json:"version"`
+
+	OutputDeviceID string `json:"output_device_id"`
+	InputDeviceID  string `json:"input_device_id"`
+
+	// Громкость и состояние звука, запомненные для каждого устройства
+	Devices map[string]*DeviceSettings `json:"devices,omitempty"`
+
+	// Deprecated: общая громкость до версии 2; переносится в Devices.
+	// Без сохранённого устройства остаётся до BindLegacyVolume.
+	OutputVolume *float32 `json:"output_volume,omitempty"`
+	InputVolume  *float32 `json:"input_volume,omitempty"`
+	OutputMuted  *bool    `json:"output_muted,omitempty"`
+	InputMuted   *bool    `json:"input_muted,omitempty"`
+
+	LockVolume     bool `json:"lock_volume"`
+	LockMute       bool `json:"lock_mute"`
+	LockBalance    bool `json:"lock_balance"`
+	AutoSwitch     bool `json:"auto_switch"`
+	AutostartAsked bool `json:"autostart_asked"`
+
+	// Что делает автопереключение: "lock" (пусто) возвращает сохранённые
+	// устройства, "follow" делает устройством по умолчанию только что
+	// подключённое и возвращает прежнее, когда оно уходит
+	SwitchMode string `json:"switch_mode,omitempty"`
+
+	// Устройства для отдельных ролей Windows ("console", "multimedia",
+	// "communications"). Роль без записи использует OutputDeviceID/InputDeviceID.
+	OutputRoleDevices map[string]string `json:"output_role_devices,omitempty"`
+	InputRoleDevices  map[string]string `json:"input_role_devices,omitempty"`
+
+	// Устройства в порядке предпочтения: используется первое активное.
+	// OutputDeviceID/InputDeviceID всегда равны первому элементу списка.
+	OutputPriority []string `json:"output_priority,omitempty"`
+	InputPriority  []string `json:"input_priority,omitempty"`
+
+	// Устройства, которые никогда не должны становиться устройством
+	// по умолчанию: если Windows выберет такое, AutoSound переключит
+	// на лучшее разрешённое, даже когда своё устройство не сохранено
+	// и автопереключение выключено (но не на паузе)
+	OutputBlocklist []BlockRule `json:"output_blocklist,omitempty"`
+	InputBlocklist  []BlockRule `json:"input_blocklist,omitempty"`
+
+	// Показывать отключённые и отсутствующие устройства в списках
+	ShowInactiveDevices bool `json:"show_inactive_devices"`
+
+	// Плавное изменение громкости: длительность в миллисекундах (0 - сразу)
+	// и кривая ("linear" или "exponential")
+	VolumeRampMs    int    `json:"volume_ramp_ms"`
+	VolumeRampCurve string `json:"volume_ramp_curve,omitempty"`
+
+	// Режим обучения: устройство, выбранное в обход AutoSound, держится
+	// LearnGraceSeconds секунд (0 - 10 секунд), затем AutoSound спрашивает,
+	// сохранить его или вернуть прежнее. Без ответа применяется
+	// LearnDefaultAction: "restore" (пусто) или "adopt".
+	LearnMode          bool   `json:"learn_mode"`
+	LearnGraceSeconds  int    `json:"learn_grace_seconds"`
+	LearnDefaultAction string `json:"learn_default_action,omitempty"`
+
+	// Восстановление устройств и фиксации приостановлено до этого момента
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+
+	// Дневная доза звука в наушниках: бюджет в минутах на полной громкости
+	// (0 - без ограничения) и действие при превышении: "warn" или "lower"
+	ExposureBudgetMinutes int    `json:"exposure_budget_minutes"`
+	ExposureAction        string `json:"exposure_action,omitempty"`
+}
+
+// DeviceSettings хранит то, что AutoSound помнит об отдельном устройстве.
+// nil - значение не задано, поэтому громкость 0.0 фиксируется так же,
+// как любая другая.
+type DeviceSettings struct {
+	// Громкость, выставленная через AutoSound; при фиксации удерживается
+	Volume *float32 `json:"volume,omitempty"`
+	Muted  *bool    `json:"muted,omitempty"`
+	// Громкость в децибелах, если её задали в dB: тогда фиксация
+	// удерживает именно это значение, а Volume хранит скаляр для слайдера
+	VolumeDB *float32 `json:"volume_db,omitempty"`
+
+	// Громкость каналов (0.0 - 1.0), задающая баланс
+	Channels []float32 `json:"channels,omitempty"`
+
+	// Допустимый диапазон громкости: внутри него громкость меняется свободно,
+	// а выход за границу возвращается к ближайшей границе
+	MinVolume *float32 `json:"min_volume,omitempty"`
+	MaxVolume *float32 `json:"max_volume,omitempty"`
+
+	// Потолок громкости для защиты слуха: в отличие от диапазона действует
+	// всегда, в том числе поверх зафиксированной громкости
+	VolumeCap *float32 `json:"volume_cap,omitempty"`
+
+	// Кривая слайдера: "linear" (пусто), "log" или "custom". Для "custom"
+	// точки [положение слайдера, громкость] задают ломаную. Громкость выше
+	// (Volume, MinVolume, MaxVolume) хранится как громкость устройства.
+	Curve       string       `json:"curve,omitempty"`
+	CurvePoints [][2]float32 `json:"curve_points,omitempty"`
+
+	// Не делать устройство устройством по умолчанию при подключении
+	// в режиме "follow"
+	NoFollow bool `json:"no_follow,omitempty"`
+}
+
+// HasLockedVolume сообщает, есть ли громкость, которую удерживает фиксация
+func (d DeviceSettings) HasLockedVolume() bool {
+	return d.Volume != nil || d.VolumeDB != nil
+}
+
+// HasVolumeLimits сообщает, задана ли хотя бы одна граница громкости
+func (d DeviceSettings) HasVolumeLimits() bool {
+	return d.MinVolume != nil || d.MaxVolume != nil
+}
+
+// LimitVolume приводит громкость к допустимому диапазону устройства
+func (d DeviceSettings) LimitVolume(level float32) float32 {
+	if d.MinVolume != nil && level < *d.MinVolume {
+		level = *d.MinVolume
+	}
+	if d.MaxVolume != nil && level > *d.MaxVolume {
+		level = *d.MaxVolume
+	}
+	return level
+}
+
+// CapVolume ограничивает громкость потолком устройства
+func (d DeviceSettings) CapVolume(level float32) float32 {
+	if d.VolumeCap != nil && level > *d.VolumeCap {
+		return *d.VolumeCap
+	}
+	return level
+}
+
+// BlockRule запрещает устройство по ID или по шаблону имени
+type BlockRule struct {
+	DeviceID string `json:"device_id,omitempty"`
+	// Шаблон имени без учёта регистра: * - любые символы, ? - один символ,
+	// \ экранирует следующий символ
+	Pattern string `json:"pattern,omitempty"`
+	// Роли, для которых действует запрет; пусто - для всех ролей
+	Roles []string `json:"roles,omitempty"`
+}
+
+// Matches сообщает, запрещает ли правило устройство для роли.
+// Ошибочный шаблон не совпадает ни с чем; проверяет его CheckPattern.
+func (r BlockRule) Matches(deviceID, name, role string) bool {
+	if len(r.Roles) > 0 && !slices.Contains(r.Roles, role) {
+		return false
+	}
+	if r.DeviceID != "" && r.DeviceID == deviceID {
+		return true
+	}
+	if r.Pattern == "" {
+		return false
+	}
+	matched, err := MatchPattern(r.Pattern, name)
+	return err == nil && matched
+}
+
+// ErrBadPattern - шаблон имени заканчивается экранирующим \
+var ErrBadPattern = errors.New("pattern ends with an unfinished escape")
+
+// CheckPattern проверяет шаблон имени устройства
+func CheckPattern(pattern string) error {
+	_, err := MatchPattern(pattern, "")
+	return err
+}
+
+// MatchPattern сопоставляет имя устройства с шаблоном без учёта регистра.
+// В отличие от path.Match, * совпадает и с '/', а [ ] - обычные символы:
+// в именах устройств нет путей.
+func MatchPattern(pattern, name string) (bool, error) {
+	p := []rune(strings.ToLower(pattern))
+	n := []rune(strings.ToLower(name))
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\\' {
+			if i == len(p)-1 {
+				return false, ErrBadPattern
+			}
+			i++
+		}
+	}
+
+	// Последняя * и позиция в имени, с которой она сейчас совпадает:
+	// при несовпадении * забирает ещё один символ
+	star, mark := -1, 0
+	pi, ni := 0, 0
+	for ni < len(n) {
+		if pi < len(p) {
+			switch c := p[pi]; {
+			case c == '*':
+				star, mark = pi, ni
+				pi++
+				continue
+			case c == '?':
+				pi++
+				ni++
+				continue
+			case c == '\\':
+				if p[pi+1] == n[ni] {
+					pi += 2
+					ni++
+					continue
+				}
+			case c == n[ni]:
+				pi++
+				ni++
+				continue
+			}
+		}
+		if star < 0 {
+			return false, nil
+		}
+		mark++
+		pi, ni = star+1, mark
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p), nil
+}
+
+// Device возвращает копию настроек устройства (пустую, если их нет)
+func (s *Settings) Device(deviceID string) DeviceSettings {
+	if dev := s.Devices[deviceID]; dev != nil {
+		return *dev
+	}
+	return DeviceSettings{}
+}
+
+// EnsureDevice возвращает настройки устройства для изменения,
+// создавая запись при необходимости
+func (s *Settings) EnsureDevice(deviceID string) *DeviceSettings {
+	if s.Devices == nil {
+		s.Devices = make(map[string]*DeviceSettings)
+	}
+	dev := s.Devices[deviceID]
+	if dev == nil {
+		dev = &DeviceSettings{}
+		s.Devices[deviceID] = dev
+	}
+	return dev
 }
 
 // SettingsManager управляет настройками
@@ -40,14 +274,18 @@ func NewSettingsManager() (*SettingsManager, error) {
 		}
 	}
 
-	settingsDir := filepath.Join(appData, "AutoSound")
+	return NewSettingsManagerInDir(filepath.Join(appData, "AutoSound"))
+}
+
+// NewSettingsManagerInDir создает менеджер настроек с файлом в указанной папке
+func NewSettingsManagerInDir(settingsDir string) (*SettingsManager, error) {
 	if err := os.MkdirAll(settingsDir, 0755); err != nil {
 		return nil, err
 	}
 
 	return &SettingsManager{
 		filePath: filepath.Join(settingsDir, "settings.json"),
-		settings: &Settings{AutoSwitch: true},
+		settings: &Settings{Version: CurrentVersion, AutoSwitch: true},
 	}, nil
 }
 
@@ -62,13 +300,84 @@ func (sm *SettingsManager) Load() (*Settings, error) {
 		return nil, err
 	}
 
+	// Поле version отсутствует в старых файлах и должно остаться нулём
+	sm.settings.Version = 0
 	if err := json.Unmarshal(data, sm.settings); err != nil {
 		return nil, err
 	}
+	sm.settings.migrate()
 
 	return sm.settings, nil
 }
 
+// migrate приводит настройки из старых версий к текущему формату
+func (s *Settings) migrate() {
+	if s.Version < 1 {
+		// До версии 1 громкость 0 означала "не задано" и не фиксировалась
+		if s.OutputVolume != nil && *s.OutputVolume == 0 {
+			s.OutputVolume = nil
+		}
+		if s.InputVolume != nil && *s.InputVolume == 0 {
+			s.InputVolume = nil
+		}
+	}
+	if s.Version < 2 {
+		// Общая громкость относилась к сохранённому устройству; без него -
+		// к устройству по умолчанию, которое станет известно при проверке
+		s.BindLegacyVolume(s.OutputDeviceID, s.InputDeviceID)
+	}
+	s.Version = CurrentVersion
+
+	// Единственное сохранённое устройство становится списком из одного элемента
+	if len(s.OutputPriority) == 0 && s.OutputDeviceID != "" {
+		s.OutputPriority = []string{s.OutputDeviceID}
+	}
+	if len(s.InputPriority) == 0 && s.InputDeviceID != "" {
+		s.InputPriority = []string{s.InputDeviceID}
+	}
+}
+
+// BindLegacyVolume переносит общую громкость из настроек до версии 2
+// в настройки устройств outputID и inputID. Направление с пустым ID
+// остаётся как есть до следующего вызова. Возвращает true, если
+// настройки изменились.
+func (s *Settings) BindLegacyVolume(outputID, inputID string) bool {
+	changed := false
+	if s.migrateDeviceVolume(outputID, s.OutputVolume, s.OutputMuted) {
+		s.OutputVolume, s.OutputMuted = nil, nil
+		changed = true
+	}
+	if s.migrateDeviceVolume(inputID, s.InputVolume, s.InputMuted) {
+		s.InputVolume, s.InputMuted = nil, nil
+		changed = true
+	}
+	return changed
+}
+
+// HasLegacyVolume сообщает, осталась ли общая громкость, которую
+// BindLegacyVolume ещё не перенесла
+func (s *Settings) HasLegacyVolume() bool {
+	return s.OutputVolume != nil || s.OutputMuted != nil ||
+		s.InputVolume != nil || s.InputMuted != nil
+}
+
+// migrateDeviceVolume переносит общую громкость в настройки устройства,
+// не затирая уже запомненную. Возвращает false, если переносить некуда
+// или нечего.
+func (s *Settings) migrateDeviceVolume(deviceID string, volume *float32, muted *bool) bool {
+	if deviceID == "" || (volume == nil && muted == nil) {
+		return false
+	}
+	dev := s.EnsureDevice(deviceID)
+	if dev.Volume == nil {
+		dev.Volume = volume
+	}
+	if dev.Muted == nil {
+		dev.Muted = muted
+	}
+	return true
+}
+
 // Save сохраняет настройки в файл
 func (sm *SettingsManager) Save(settings *Settings) error {
 	sm.settings = settings
@@ -90,41 +399,3 @@ func (sm *SettingsManager) GetSettings() *Settings {
 func (sm *SettingsManager) GetFilePath() string {
 	return sm.filePath
 }
-
-// IsAutostartEnabled проверяет включен ли автозапуск в реестре
-func IsAutostartEnabled() bool {
-	key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.QUERY_VALUE)
-	if err != nil {
-		return false
-	}
-	defer key.Close()
-
-	_, _, err = key.GetStringValue(registryValue)
-	return err == nil
-}
-
-// SetAutostart включает или выключает автозапуск
-func SetAutostart(enabled bool) error {
-	if enabled {
-		exePath, err := os.Executable()
-		if err != nil {
-			return err
-		}
-
-		key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.SET_VALUE)
-		if err != nil {
-			return err
-		}
-		defer key.Close()
-
-		return key.SetStringValue(registryValue, `"`+exePath+`"`)
-	} else {
-		key, err := registry.OpenKey(registry.CURRENT_USER, registryKey, registry.SET_VALUE)
-		if err != nil {
-			return err
-		}
-		defer key.Close()
-
-		return key.DeleteValue(registryValue)
-	}
-}
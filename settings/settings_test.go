@@ -0,0 +1,205 @@
+package settings
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// loadJSON загружает настройки из файла с содержимым data
+func loadJSON(t *testing.T, data string) *Settings {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "settings.json"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sm, err := NewSettingsManagerInDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := sm.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestMigrateMovesVolumeToSavedDevice(t *testing.T) {
+	s := loadJSON(t, `{
+		"version": 1,
+		"output_device_id": "speakers",
+		"input_device_id": "mic",
+		"output_volume": 0.5,
+		"output_muted": true,
+		"input_volume": 0.75
+	}`)
+
+	if s.Version != CurrentVersion {
+		t.Errorf("version = %d, want %d", s.Version, CurrentVersion)
+	}
+	if s.HasLegacyVolume() {
+		t.Error("legacy volume is kept although devices are saved")
+	}
+	out := s.Device("speakers")
+	if out.Volume == nil || *out.Volume != 0.5 || out.Muted == nil || !*out.Muted {
+		t.Errorf("speakers = %+v, want volume 0.5 muted", out)
+	}
+	if in := s.Device("mic"); in.Volume == nil || *in.Volume != 0.75 || in.Muted != nil {
+		t.Errorf("mic = %+v, want volume 0.75", in)
+	}
+}
+
+func TestMigrateKeepsVolumeWithoutSavedDevice(t *testing.T) {
+	s := loadJSON(t, `{
+		"version": 1,
+		"input_device_id": "mic",
+		"output_volume": 0.5,
+		"output_muted": false,
+		"input_volume": 0.75
+	}`)
+
+	if s.OutputVolume == nil || *s.OutputVolume != 0.5 || s.OutputMuted == nil || *s.OutputMuted {
+		t.Fatalf("legacy output = %v/%v, want 0.5/false", s.OutputVolume, s.OutputMuted)
+	}
+	if s.InputVolume != nil {
+		t.Errorf("legacy input volume = %v, want moved to mic", *s.InputVolume)
+	}
+	if len(s.Devices) != 1 {
+		t.Errorf("devices = %v, want only mic", s.Devices)
+	}
+
+	// Пока устройство по умолчанию неизвестно, значение ждёт
+	if s.BindLegacyVolume("", "") {
+		t.Error("nothing to bind without devices, but settings changed")
+	}
+	if !s.BindLegacyVolume("headphones", "") {
+		t.Fatal("legacy output is not bound")
+	}
+	if s.HasLegacyVolume() {
+		t.Error("legacy volume is kept after binding")
+	}
+	if dev := s.Device("headphones"); dev.Volume == nil || *dev.Volume != 0.5 || dev.Muted == nil || *dev.Muted {
+		t.Errorf("headphones = %+v, want volume 0.5 unmuted", dev)
+	}
+}
+
+func TestBindLegacyVolumeKeepsDeviceVolume(t *testing.T) {
+	volume, saved := float32(0.5), float32(0.25)
+	s := &Settings{OutputVolume: &volume}
+	s.EnsureDevice("headphones").Volume = &saved
+
+	s.BindLegacyVolume("headphones", "")
+	if dev := s.Device("headphones"); *dev.Volume != 0.25 {
+		t.Errorf("headphones volume = %v, want remembered 0.25", *dev.Volume)
+	}
+}
+
+func TestMigrateDropsZeroVolumeBeforeVersion1(t *testing.T) {
+	s := loadJSON(t, `{"output_volume": 0, "input_volume": 0.25}`)
+
+	if s.OutputVolume != nil {
+		t.Errorf("legacy output volume = %v, want unset", *s.OutputVolume)
+	}
+	if s.InputVolume == nil || *s.InputVolume != 0.25 {
+		t.Errorf("legacy input volume = %v, want 0.25", s.InputVolume)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"Speakers", "Speakers", true},
+		{"Speakers", "Speakers 2", false},
+		{"speak*", "Speakers (Realtek Audio)", true},
+		{"*realtek*", "Speakers (Realtek Audio)", true},
+		{"*", "", true},
+		{"*", "Anything/with/slashes", true},
+		{"*usb", "USB Headset", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+		{"?", "", false},
+		{"?", "1", true},
+		{"Speakers ?", "Speakers 2", true},
+		{"Speakers ?", "Speakers 12", false},
+		{"??*", "a", false},
+		{"HEADPHONES", "headphones", true},
+		{"наушники*", "НАУШНИКИ (Bluetooth)", true},
+		{"", "", true},
+		{"", "Speakers", false},
+		{`a\*b`, "a*b", true},
+		{`a\*b`, "axb", false},
+		{`\?`, "?", true},
+		{`\?`, "x", false},
+		{`*\\`, `Speakers\`, true},
+		{"[usb]*", "[USB] Headset", true},
+		{"[usb]*", "u Headset", false},
+	}
+
+	for _, tt := range tests {
+		got, err := MatchPattern(tt.pattern, tt.name)
+		if err != nil {
+			t.Errorf("MatchPattern(%q, %q) error: %v", tt.pattern, tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		wantErr bool
+	}{
+		{"", false},
+		{"*", false},
+		{"speak?rs*", false},
+		{`\\`, false},
+		{`a\*`, false},
+		{"[unclosed", false},
+		{`\`, true},
+		{`speak\`, true},
+		{`a*\`, true},
+		{`\\\`, true},
+	}
+
+	for _, tt := range tests {
+		err := CheckPattern(tt.pattern)
+		if tt.wantErr != (err != nil) {
+			t.Errorf("CheckPattern(%q) = %v, want error %v", tt.pattern, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrBadPattern) {
+			t.Errorf("CheckPattern(%q) = %v, want ErrBadPattern", tt.pattern, err)
+		}
+		if _, matchErr := MatchPattern(tt.pattern, "Speakers"); (matchErr != nil) != tt.wantErr {
+			t.Errorf("MatchPattern(%q) error = %v, want error %v", tt.pattern, matchErr, tt.wantErr)
+		}
+	}
+}
+
+func TestBlockRuleMatches(t *testing.T) {
+	tests := []struct {
+		name string
+		rule BlockRule
+		want bool
+	}{
+		{"by id", BlockRule{DeviceID: "hdmi"}, true},
+		{"other id", BlockRule{DeviceID: "usb"}, false},
+		{"by pattern", BlockRule{Pattern: "*HDMI*"}, true},
+		{"empty pattern matches nothing", BlockRule{Pattern: ""}, false},
+		{"invalid pattern matches nothing", BlockRule{Pattern: `*\`}, false},
+		{"role listed", BlockRule{DeviceID: "hdmi", Roles: []string{"console"}}, true},
+		{"role not listed", BlockRule{DeviceID: "hdmi", Roles: []string{"communications"}}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Matches("hdmi", "Monitor (HDMI Audio)", "console"); got != tt.want {
+			t.Errorf("%s: Matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}